@@ -59,6 +59,10 @@ using (var csv = new CsvHelper.CsvReader(reader, CultureInfo.InvariantCulture))
 
             foreach (CompetitorData comp in competitors)
             {
+                if (comp.our_car)
+                {
+                    continue;
+                }
 
                 var overtakingProbability = funcs.Function4(
                     yourAvgSpeed,
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"dataGen/generator"
+)
+
+// runCalibrate implements the calibrate command: it fits the telemetry model
+// to a reference session and writes a calibration file for -calibration
+func runCalibrate(args []string) {
+	flags := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	input := flags.String("in", "", "reference telemetry CSV")
+	output := flags.String("out", "./data/calibration.json", "calibration file to write")
+	paramsFile := flags.String("params", "", "race parameters CSV of the reference session (default Monaco)")
+	flags.Parse(args)
+
+	if *input == "" {
+		fmt.Println("Usage: dataGen calibrate -in reference.csv [-out calibration.json] [-params race_parameters.csv]")
+		os.Exit(2)
+	}
+
+	params := generator.MonacoParameters()
+	if *paramsFile != "" {
+		var err error
+		if params, err = readParams(*paramsFile); err != nil {
+			fmt.Printf("Error reading race parameters: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	file, err := os.Open(*input)
+	if err != nil {
+		fmt.Printf("Error reading reference telemetry: %v\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	cal, err := generator.Calibrate(file, &params)
+	if err != nil {
+		fmt.Printf("Error calibrating: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := writeFile(*output, func(w io.Writer) error {
+		return generator.WriteCalibration(w, cal)
+	}); err != nil {
+		fmt.Printf("Error writing calibration: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Calibrated %d track segments from %s\n", len(cal.Segments), *input)
+	fmt.Printf("- speed noise: %.2f km/h\n", cal.SpeedNoise)
+	fmt.Printf("- fuel flow: %.2f + %.3f * throttle kg/h\n", cal.FuelFlow.Base, cal.FuelFlow.Throttle)
+	fmt.Printf("- tire temperature rise: %.2f C/lap (FL)\n", cal.TireTemps.FL.PerLap)
+	fmt.Printf("Wrote %s\n", *output)
+}
+
+// readCalibration loads a calibration file written by the calibrate command
+func readCalibration(filename string) (generator.Calibration, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return generator.Calibration{}, err
+	}
+	defer file.Close()
+	return generator.ReadCalibration(file)
+}
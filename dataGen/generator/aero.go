@@ -0,0 +1,150 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Damage causes
+const (
+	DamageBarrier = "barrier" // contact with the barriers at a corner
+	DamageDebris  = "debris"  // running over debris
+	DamageContact = "contact" // contact with another car in the first corner
+)
+
+// DamageEvent is a moment a car picks up aerodynamic damage, recorded in the
+// ground truth. Scenarios set Lap, Progress, Cause and Damage; the rest is
+// filled in by the generator
+type DamageEvent struct {
+	CarNumber   int     `json:"car_number"`
+	Lap         int     `json:"lap"`
+	Progress    float64 `json:"progress"`     // lap progress of the event
+	Cause       string  `json:"cause"`        // DamageBarrier, DamageDebris or DamageContact
+	Damage      float64 `json:"damage"`       // aero damage fraction added
+	TotalDamage float64 `json:"total_damage"` // aero damage fraction after the event
+}
+
+// Damage event model. Barrier contact follows a driver mistake at a corner,
+// debris can be picked up anywhere on the lap
+const (
+	barrierContactChance = 0.05  // chance a mistake ends in contact with the barrier
+	debrisChance         = 0.004 // chance of debris damage per lap
+	damageStream         = -2    // random stream damage events are drawn from
+	maxAeroDamage        = 0.9   // damage beyond this leaves the car undriveable
+)
+
+// Damage added by each cause, as aero damage fractions
+var (
+	barrierDamage = Range{0.03, 0.2}
+	debrisDamage  = Range{0.01, 0.06}
+	contactDamage = Range{0.02, 0.15}
+)
+
+// validateDamageEvents checks scenario damage events against a session of
+// laps laps
+func validateDamageEvents(events []DamageEvent, laps int) error {
+	for _, e := range events {
+		switch {
+		case e.Lap < 1 || e.Lap > laps:
+			return fmt.Errorf("generator: damage lap %d outside range [1, %d]", e.Lap, laps)
+		case e.Progress < 0 || e.Progress >= 1:
+			return fmt.Errorf("generator: damage progress %g outside range [0, 1)", e.Progress)
+		case e.Damage <= 0 || e.Damage > 1:
+			return errors.New("generator: damage must be in (0, 1]")
+		}
+	}
+	return nil
+}
+
+// planDamage returns the damage events of a car over the session: events
+// when given, otherwise drawn at random from the car's own stream along
+// with any contact at the start. Total damage builds up from the race
+// parameters' aero damage percentage
+func planDamage(opts Options, carNumber int, driver Driver, start *RaceStart, events []DamageEvent) []DamageEvent {
+	p := opts.parameters()
+	if events == nil {
+		s := newSampler(deriveSeed(opts.Seed, carNumber, damageStream))
+		contact := driver.MistakeProbability * barrierContactChance
+		for lap := 1; lap <= opts.Laps; lap++ {
+			for _, c := range monacoCorners {
+				if s.float64() < contact {
+					events = append(events, DamageEvent{Lap: lap, Progress: c.Apex, Cause: DamageBarrier,
+						Damage: s.uniformRandom(barrierDamage.Min, barrierDamage.Max)})
+				}
+			}
+			if s.float64() < debrisChance {
+				events = append(events, DamageEvent{Lap: lap, Progress: s.float64(), Cause: DamageDebris,
+					Damage: s.uniformRandom(debrisDamage.Min, debrisDamage.Max)})
+			}
+		}
+		if start != nil && start.Incident {
+			events = append(events, DamageEvent{Lap: 1, Progress: monacoCorners[0].Apex, Cause: DamageContact,
+				Damage: s.uniformRandom(contactDamage.Min, contactDamage.Max)})
+		}
+	}
+	events = append([]DamageEvent(nil), events...)
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Lap != events[j].Lap {
+			return events[i].Lap < events[j].Lap
+		}
+		return events[i].Progress < events[j].Progress
+	})
+
+	total := math.Min(p.AeroDamagePercentage, maxAeroDamage)
+	for i := range events {
+		events[i].CarNumber = carNumber
+		events[i].Damage = round3(events[i].Damage)
+		total = math.Min(total+events[i].Damage, maxAeroDamage)
+		events[i].TotalDamage = round3(total)
+	}
+	return events
+}
+
+// damageAt returns the aero damage fraction at lapProgress on lap, starting
+// from initial before any event
+func damageAt(events []DamageEvent, initial float64, lap int, lapProgress float64) float64 {
+	damage := initial
+	for _, e := range events {
+		if e.Lap > lap || e.Lap == lap && e.Progress > lapProgress {
+			break
+		}
+		damage = e.TotalDamage
+	}
+	return damage
+}
+
+// aeroFactors returns the straight-line and cornering speed multipliers of
+// running with damage instead of the race parameters' aero damage, from
+//
+//	drag_coefficient = base_drag + (damage_factor * aero_damage_percentage)
+//	downforce_loss = base_downforce * (1 - aero_damage_percentage)
+//	straight_line_speed = max_speed * (1 - drag_coefficient * air_density_factor)
+//	cornering_speed = base_corner_speed * sqrt(downforce_loss / base_downforce)
+func aeroFactors(p *RaceParameters, damage float64) (straight, cornering float64) {
+	straightSpeed := func(damage float64) float64 {
+		return math.Max(1-(p.BaseDrag+p.DamageFactor*damage)*p.AirDensityFactor, 0.05)
+	}
+	straight = straightSpeed(damage) / straightSpeed(p.AeroDamagePercentage)
+	cornering = math.Sqrt((1 - damage) / math.Max(1-p.AeroDamagePercentage, 1-maxAeroDamage))
+	return straight, cornering
+}
+
+// aeroSpeedFactor returns the speed multiplier of damage at speed km/h:
+// slow corners depend on downforce, fast sections on drag
+func aeroSpeedFactor(p *RaceParameters, damage, speed float64) float64 {
+	if damage == p.AeroDamagePercentage {
+		return 1
+	}
+	straight, cornering := aeroFactors(p, damage)
+	w := clamp((speed-slowZoneSpeed)/(fastZoneSpeed-slowZoneSpeed), 0, 1)
+	return w*straight + (1-w)*cornering
+}
+
+// aeroLapTimeFactor returns the lap time multiplier of damage over a lap
+// split evenly between corners and straights
+func aeroLapTimeFactor(p *RaceParameters, damage float64) float64 {
+	straight, cornering := aeroFactors(p, damage)
+	return 2 / (straight + cornering)
+}
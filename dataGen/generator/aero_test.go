@@ -0,0 +1,67 @@
+package generator
+
+import (
+	"context"
+	"testing"
+)
+
+// TestDamageSlowsCar checks that a scenario damage event shows in the damage
+// channel from its lap progress on, costs speed on the straights and in the
+// corners, and is recorded in the ground truth
+func TestDamageSlowsCar(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Laps = 2
+	opts.DamageEvents = []DamageEvent{}
+	clean, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.DamageEvents = []DamageEvent{{Lap: 1, Progress: 0.5, Cause: DamageBarrier, Damage: 0.3}}
+	damaged, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []DamageEvent
+	for _, e := range damaged.GroundTruth.Damage {
+		if e.CarNumber == opts.carNumber() {
+			events = append(events, e)
+		}
+	}
+	if len(events) != 1 || events[0].TotalDamage != 0.33 {
+		t.Fatalf("ground truth damage %+v, want one event to 0.33", events)
+	}
+
+	// Compare lap 2 at the same points on the lap, as the damaged car
+	// takes longer over it
+	p := damaged.RaceParameters
+	var cleanFast, damagedFast, cleanSlow, damagedSlow float64
+	j := 0
+	for i := 0; i < damaged.Telemetry.Len(); i++ {
+		d, c := damaged.Telemetry, clean.Telemetry
+		progress := d.Distance[i]/p.TrackLength - float64(d.Lap[i]-1)
+		want := p.AeroDamagePercentage
+		if d.Lap[i] > 1 || progress >= 0.5 {
+			want = 0.33
+		}
+		if d.AeroDamage[i] != want {
+			t.Fatalf("aero damage %v at sample %d, want %v", d.AeroDamage[i], i, want)
+		}
+		if d.Lap[i] < 2 {
+			continue
+		}
+		for j < c.Len()-1 && c.Distance[j+1] <= d.Distance[i] {
+			j++
+		}
+		if c.Speed[j] > fastZoneSpeed {
+			cleanFast += c.Speed[j]
+			damagedFast += d.Speed[i]
+		} else if c.Speed[j] < slowZoneSpeed {
+			cleanSlow += c.Speed[j]
+			damagedSlow += d.Speed[i]
+		}
+	}
+	if damagedFast >= cleanFast || damagedSlow >= cleanSlow {
+		t.Errorf("damage left speeds at fast %.0f/%.0f and slow %.0f/%.0f", damagedFast, cleanFast, damagedSlow, cleanSlow)
+	}
+}
@@ -0,0 +1,186 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// benchmarkRates are the telemetry sample rates benchmarked, in samples per second
+var benchmarkRates = []float64{10, 100, 1000}
+
+// benchmarkOptions returns default options at sampleRate on a single worker,
+// so results measure the generator rather than the scheduler
+func benchmarkOptions(sampleRate float64) Options {
+	opts := DefaultOptions()
+	opts.SampleRate = sampleRate
+	opts.Workers = 1
+	return opts
+}
+
+// benchmarkLap generates the first lap of our car at sampleRate
+func benchmarkLap(b *testing.B, sampleRate float64) *TelemetryData {
+	b.Helper()
+	opts := benchmarkOptions(sampleRate)
+	model := newTelemetryModel(opts, ourCar(opts, nil))
+	plan := model.newPlanner(newSampler(1)).next()
+	return model.generateLap(newSampler(2), plan)
+}
+
+// reportSamples reports throughput in samples per second
+func reportSamples(b *testing.B, samplesPerOp int) {
+	b.ReportMetric(float64(samplesPerOp)*float64(b.N)/b.Elapsed().Seconds(), "samples/s")
+}
+
+func BenchmarkPlanLap(b *testing.B) {
+	for _, rate := range benchmarkRates {
+		b.Run(fmt.Sprintf("rate=%g", rate), func(b *testing.B) {
+			opts := benchmarkOptions(rate)
+			model := newTelemetryModel(opts, ourCar(opts, nil))
+			var planner *lapPlanner
+			samples := 0
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// A planner runs out of laps at the end of the session
+				if i%opts.Laps == 0 {
+					b.StopTimer()
+					planner = model.newPlanner(newSampler(1))
+					b.StartTimer()
+				}
+				samples += len(planner.next().speeds)
+			}
+			b.ReportMetric(float64(samples)/b.Elapsed().Seconds(), "samples/s")
+		})
+	}
+}
+
+func BenchmarkGenerateLap(b *testing.B) {
+	for _, rate := range benchmarkRates {
+		b.Run(fmt.Sprintf("rate=%g", rate), func(b *testing.B) {
+			opts := benchmarkOptions(rate)
+			model := newTelemetryModel(opts, ourCar(opts, nil))
+			plan := model.newPlanner(newSampler(1)).next()
+			s := newSampler(2)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				model.generateLap(s, plan)
+			}
+			reportSamples(b, model.samplesPerLap)
+		})
+	}
+}
+
+func BenchmarkStreamTelemetry(b *testing.B) {
+	for _, rate := range benchmarkRates {
+		b.Run(fmt.Sprintf("rate=%g", rate), func(b *testing.B) {
+			opts := benchmarkOptions(rate)
+			opts.Laps = 3
+			samples := 0
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				samples = 0
+				err := StreamTelemetry(context.Background(), opts, func(lap *TelemetryData) error {
+					samples += lap.Len()
+					return nil
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			reportSamples(b, samples)
+		})
+	}
+}
+
+func BenchmarkTelemetryCSVWriter(b *testing.B) {
+	for _, rate := range benchmarkRates {
+		b.Run(fmt.Sprintf("rate=%g", rate), func(b *testing.B) {
+			lap := benchmarkLap(b, rate)
+			var out bytes.Buffer
+			if err := WriteTelemetryCSV(&out, lap); err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(out.Len()))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := WriteTelemetryCSV(io.Discard, lap); err != nil {
+					b.Fatal(err)
+				}
+			}
+			reportSamples(b, lap.Len())
+		})
+	}
+}
+
+func BenchmarkGridTelemetryCSVWriter(b *testing.B) {
+	lap := benchmarkLap(b, 100)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		writer, err := NewGridTelemetryCSVWriter(io.Discard)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for car := 1; car <= 20; car++ {
+			if err := writer.WriteCar(car, lap); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportSamples(b, 20*lap.Len())
+}
+
+func BenchmarkWriteRaceParameters(b *testing.B) {
+	writers := []struct {
+		name  string
+		write func(io.Writer, RaceParameters) error
+	}{
+		{"csv", WriteRaceParametersCSV},
+		{"json", WriteRaceParametersJSON},
+		{"yaml", WriteRaceParametersYAML},
+	}
+	params := MonacoParameters()
+	for _, w := range writers {
+		b.Run(w.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := w.write(io.Discard, params); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkWriteCompetitorCSV(b *testing.B) {
+	result, err := GenerateSession(context.Background(), DefaultOptions())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := WriteCompetitorCSV(io.Discard, result.Competitors); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteTimingFeed(b *testing.B) {
+	result, err := GenerateSession(context.Background(), DefaultOptions())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := WriteTimingFeed(io.Discard, result.TimingFeed); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package generator
+
+import "math"
+
+// Wheel positions, front pair first
+const (
+	wheelFL = iota
+	wheelFR
+	wheelRL
+	wheelRR
+	wheelCount
+)
+
+// Brake thermal model. Each disc is heated by its share of the braking power
+// and cools towards the track temperature faster at speed
+const (
+	brakeHeating     = 2.4    // K per (m/s² · m/s) of deceleration power at full share
+	rearDiscHeating  = 1.2    // rear discs are lighter and heat faster
+	brakeCooling     = 0.015  // 1/s cooling rate when stationary
+	brakeAirCooling  = 0.0003 // extra 1/s cooling rate per km/h
+	brakeStartTemp   = 400    // °C of the discs at the start of the session
+	maxBrakeTemp     = 1200   // °C
+	dragDeceleration = 0.5    // m/s² of deceleration from rolling resistance
+	aeroDeceleration = 10     // extra m/s² of drag deceleration at top speed
+)
+
+// Braking into a corner loads the outside wheels, which take more of their
+// axle's braking
+const (
+	brakeLoadShift    = 0.1 // share of an axle's braking moved outside per g of cornering
+	maxBrakeLoadShift = 0.4
+)
+
+// Brake bias limits in percent to the front
+const (
+	minBrakeBias = 52
+	maxBrakeBias = 62
+)
+
+// baseBrakeBias returns the brake bias a driver starts from, in percent to
+// the front. Aggressive brakers run more bias forward
+func (d Driver) baseBrakeBias() float64 {
+	return 57 + 2*(d.BrakingAggression-0.5)
+}
+
+// nextBrakeBias returns the bias for the next lap: the driver moves it
+// rearward after locking a front wheel, otherwise occasionally steps it
+// back towards their base setting
+func (d Driver) nextBrakeBias(s *sampler, bias float64, frontLockUp bool) float64 {
+	switch {
+	case frontLockUp:
+		bias -= 0.5
+	case s.float64() < 0.2 && bias != d.baseBrakeBias():
+		bias += 0.5 * math.Copysign(1, d.baseBrakeBias()-bias)
+	}
+	return clamp(bias, minBrakeBias, maxBrakeBias)
+}
+
+// lockUpScale returns how much likelier lock-ups are than for a balanced
+// driver on fresh tires at their working temperature: harder braking, worn
+// or cold tires and forward bias all lock wheels more often
+func lockUpScale(d Driver, tireDeg, tireTemp, bias float64) float64 {
+	scale := 0.5 + d.BrakingAggression
+	scale *= math.Pow(tireDeg, 10)
+	scale *= 1 + math.Max(-tireTemp, 0)/10
+	scale *= 1 + (bias-57)/10
+	return math.Max(scale, 0)
+}
+
+// coastDeceleration returns the deceleration in m/s² from drag and rolling
+// resistance at speed km/h, which the brakes do not have to provide
+func coastDeceleration(speed, maxSpeed float64) float64 {
+	ratio := speed / maxSpeed
+	return dragDeceleration + aeroDeceleration*ratio*ratio
+}
+
+// brakeTemps returns the disc temperatures after every sample of speeds
+// (km/h, dt seconds apart) starting from temps, with bias percent of the
+// braking to the front. Braking into a corner loads the outside wheels, so
+// each axle's braking shifts towards them with the lateral acceleration from
+// the signed track curvatures (1/m) under each sample
+func brakeTemps(temps [wheelCount]float64, speeds, curvatures []float64, dt, bias, maxSpeed, ambient float64) [][wheelCount]float64 {
+	const kmh = 3.6 // km/h per m/s
+	front := bias / 100
+	rear := (100 - bias) / 100 * rearDiscHeating
+
+	out := make([][wheelCount]float64, len(speeds))
+	prev := speeds[0]
+	for i, speed := range speeds {
+		// Braking power beyond what drag and rolling resistance provide
+		decel := (prev-speed)/kmh/dt - coastDeceleration(speed, maxSpeed)
+		power := math.Max(decel, 0) * speed / kmh
+		cooling := brakeCooling + brakeAirCooling*speed
+		lateral := speed * speed / (kmh * kmh) * curvatures[i] / standardGravity
+		shift := clamp(brakeLoadShift*lateral, -maxBrakeLoadShift, maxBrakeLoadShift) // Left turns load the right
+		left, right := (1-shift)/2, (1+shift)/2
+		share := [wheelCount]float64{front * left, front * right, rear * left, rear * right}
+		for w := range temps {
+			temps[w] += (brakeHeating*share[w]*power - cooling*(temps[w]-ambient)) * dt
+			temps[w] = clamp(temps[w], ambient, maxBrakeTemp)
+		}
+		out[i] = temps
+		prev = speed
+	}
+	return out
+}
+
+// lockedWheelSpeed returns the speed of a wheel at lapProgress given the
+// mistakes of the lap: a locked wheel drops below the car's speed on the
+// way into the corner and recovers by the apex
+func lockedWheelSpeed(mistakes []mistake, wheel int, lapProgress, speed float64) float64 {
+	mk, ok := mistakeAt(mistakes, lapProgress)
+	if !ok || mk.kind != mistakeLockUp || mk.wheel != wheel || lapProgress > mk.apex {
+		return speed
+	}
+	x := (lapProgress - (mk.apex - mistakeCornerWidth)) / mistakeCornerWidth
+	return speed * (1 - mk.depth*math.Sin(math.Pi*x))
+}
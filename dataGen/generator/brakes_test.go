@@ -0,0 +1,79 @@
+package generator
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// TestLockUpScale checks that hard braking, worn or cold tires and forward
+// bias all make lock-ups likelier
+func TestLockUpScale(t *testing.T) {
+	aggressive, _ := DriverProfile("aggressive")
+	smooth, _ := DriverProfile("smooth")
+	base := lockUpScale(smooth, 1, 0, 57)
+	for name, scale := range map[string]float64{
+		"aggressive":    lockUpScale(aggressive, 1, 0, 57),
+		"worn tires":    lockUpScale(smooth, 1.08, 0, 57),
+		"cold tires":    lockUpScale(smooth, 1, -12, 57),
+		"forward bias":  lockUpScale(smooth, 1, 0, 60),
+		"combined risk": lockUpScale(aggressive, 1.08, -12, 60),
+	} {
+		if scale <= base {
+			t.Errorf("%s: lock-up scale %.2f, smooth driver on fresh warm tires %.2f", name, scale, base)
+		}
+	}
+}
+
+// TestBrakeChannels checks disc temperatures heat under braking with the
+// fronts working hardest and the outside discs hotter into corners, and
+// that lock-ups drop a wheel below the car's
+// speed more often for an aggressive driver than a smooth one
+func TestBrakeChannels(t *testing.T) {
+	lockUps := map[string]int{}
+	for _, name := range []string{"aggressive", "smooth"} {
+		driver, _ := DriverProfile(name)
+		opts := DefaultOptions()
+		opts.Laps = 15
+		opts.Driver = &driver
+		result, err := Generate(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d := result.Telemetry
+		var front, rear, outside float64
+		locked := false
+		for i := 1; i < d.Len(); i++ {
+			front += d.BrakeTempFL[i] + d.BrakeTempFR[i]
+			rear += d.BrakeTempRL[i] + d.BrakeTempRR[i]
+			// The wheel turns left for left corners, which load the right discs
+			if d.Speed[i] < d.Speed[i-1] && math.Abs(d.SteeringAngle[i]) > 10 {
+				heatFR, heatFL := d.BrakeTempFR[i]-d.BrakeTempFR[i-1], d.BrakeTempFL[i]-d.BrakeTempFL[i-1]
+				outside += (heatFR - heatFL) * math.Copysign(1, d.SteeringAngle[i])
+			}
+			if d.Speed[i] < d.Speed[i-1]-8 && d.PitLimiter[i] == 0 && d.BrakeTempFL[i] <= d.BrakeTempFL[i-1] {
+				t.Fatalf("%s: front left disc cools from %v to %v braking from %v to %v km/h",
+					name, d.BrakeTempFL[i-1], d.BrakeTempFL[i], d.Speed[i-1], d.Speed[i])
+			}
+			wheels := []float64{d.WheelSpeedFL[i], d.WheelSpeedFR[i], d.WheelSpeedRL[i], d.WheelSpeedRR[i]}
+			lock := false
+			for _, w := range wheels {
+				lock = lock || w < d.Speed[i]*0.95
+			}
+			if lock && !locked {
+				lockUps[name]++
+			}
+			locked = lock
+		}
+		if front <= rear {
+			t.Errorf("%s: rear discs run hotter than the fronts", name)
+		}
+		if outside <= 0 {
+			t.Errorf("%s: inside front discs heat %.1f°C more than the outside ones braking into corners", name, -outside)
+		}
+	}
+	if lockUps["aggressive"] <= lockUps["smooth"] {
+		t.Errorf("aggressive driver locked up %d times, smooth driver %d", lockUps["aggressive"], lockUps["smooth"])
+	}
+}
@@ -0,0 +1,720 @@
+package generator
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Range is a closed interval that inputs are drawn from uniformly
+type Range struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// ZoneInputs holds the throttle and brake distributions of one driving zone
+type ZoneInputs struct {
+	Throttle Range `json:"throttle"`
+	Brake    Range `json:"brake"`
+}
+
+// InputDistributions holds driver inputs for each kind of track zone
+type InputDistributions struct {
+	Braking ZoneInputs `json:"braking"` // major braking zones
+	Fast    ZoneInputs `json:"fast"`    // above fastZoneSpeed
+	Slow    ZoneInputs `json:"slow"`    // below slowZoneSpeed
+	Medium  ZoneInputs `json:"medium"`  // everything else
+}
+
+// TireTempModel is a linear model of one tire's temperature:
+// temp = track_temp + Offset + PerLap*lap + Throttle*throttle + Brake*brake + N(0, Noise)
+type TireTempModel struct {
+	Offset   float64 `json:"offset"`
+	PerLap   float64 `json:"per_lap"`
+	Throttle float64 `json:"throttle"`
+	Brake    float64 `json:"brake"`
+	Noise    float64 `json:"noise"`
+}
+
+// TireTempModels holds the temperature model of each corner of the car
+type TireTempModels struct {
+	FL TireTempModel `json:"fl"`
+	FR TireTempModel `json:"fr"`
+	RL TireTempModel `json:"rl"`
+	RR TireTempModel `json:"rr"`
+}
+
+// FuelFlowModel is a linear model of fuel flow: Base + Throttle*throttle + N(0, Noise)
+type FuelFlowModel struct {
+	Base     float64 `json:"base"`
+	Throttle float64 `json:"throttle"`
+	Noise    float64 `json:"noise"`
+}
+
+// SegmentProfile is a calibrated speed profile for one track segment, with
+// speeds at evenly spaced points from Start to End
+type SegmentProfile struct {
+	Name   string    `json:"name"`
+	Start  float64   `json:"start"`
+	End    float64   `json:"end"`
+	Speeds []float64 `json:"speeds"`
+}
+
+// Calibration holds the statistical constants of the telemetry model.
+// DefaultCalibration returns the hand-tuned values; Calibrate fits them to a
+// reference session
+type Calibration struct {
+	Segments   []SegmentProfile   `json:"segments,omitempty"` // empty uses the built-in Monaco profile
+	SpeedNoise float64            `json:"speed_noise"`        // km/h, stationary standard deviation
+	SpeedTau   float64            `json:"speed_noise_tau"`    // seconds, noise correlation time
+	MaxAccel   float64            `json:"max_acceleration"`   // g, traction limit at low speed
+	MaxBraking float64            `json:"max_braking"`        // g, braking limit at low speed
+	RPMNoise   float64            `json:"rpm_noise"`
+	Inputs     InputDistributions `json:"inputs"`
+	TireTemps  TireTempModels     `json:"tire_temps"`
+	FuelFlow   FuelFlowModel      `json:"fuel_flow"`
+
+	knots []profileKnot // flattened Segments, see buildKnots
+}
+
+// profileKnot is one point of a calibrated speed profile
+type profileKnot struct {
+	progress, speed float64
+}
+
+// Zone speed thresholds in km/h
+const (
+	fastZoneSpeed = 140
+	slowZoneSpeed = 70
+)
+
+// DefaultCalibration returns the hand-tuned telemetry model constants
+func DefaultCalibration() Calibration {
+	return Calibration{
+		SpeedNoise: 1.5,
+		SpeedTau:   2.0,
+		MaxAccel:   1.3,
+		MaxBraking: 2.5,
+		RPMNoise:   150,
+		Inputs: InputDistributions{
+			Braking: ZoneInputs{Throttle: Range{0, 25}, Brake: Range{100, 200}},
+			Fast:    ZoneInputs{Throttle: Range{85, 100}, Brake: Range{0, 10}},
+			Slow:    ZoneInputs{Throttle: Range{30, 60}, Brake: Range{20, 50}},
+			Medium:  ZoneInputs{Throttle: Range{50, 85}, Brake: Range{0, 25}},
+		},
+		TireTemps: TireTempModels{
+			FL: TireTempModel{Offset: 48, PerLap: 2.5, Throttle: 0.15, Brake: 0.1, Noise: 4},
+			FR: TireTempModel{Offset: 48, PerLap: 2.5, Throttle: 0.12, Brake: 0.08, Noise: 4},
+			RL: TireTempModel{Offset: 48, PerLap: 2.5, Throttle: 0.18, Brake: 0.05, Noise: 3},
+			RR: TireTempModel{Offset: 48, PerLap: 2.5, Throttle: 0.15, Brake: 0.05, Noise: 3},
+		},
+		FuelFlow: FuelFlowModel{Base: 25, Throttle: 0.75, Noise: 4},
+	}
+}
+
+// buildKnots flattens the calibrated segments into interpolation knots
+func (c *Calibration) buildKnots() {
+	c.knots = nil
+	for _, seg := range c.Segments {
+		step := (seg.End - seg.Start) / float64(len(seg.Speeds))
+		for i, speed := range seg.Speeds {
+			c.knots = append(c.knots, profileKnot{seg.Start + (float64(i)+0.5)*step, speed})
+		}
+	}
+	sort.Slice(c.knots, func(i, j int) bool { return c.knots[i].progress < c.knots[j].progress })
+}
+
+// speedAt returns the reference speed at lapProgress, from the calibrated
+// segments when present and the built-in Monaco profile otherwise
+func (c *Calibration) speedAt(lapProgress float64) float64 {
+	if len(c.knots) == 0 {
+		return getMonacoSpeedProfile(lapProgress)
+	}
+
+	// Linear interpolation between knots, wrapping around the start/finish line
+	i := sort.Search(len(c.knots), func(i int) bool { return c.knots[i].progress >= lapProgress })
+	prev, next := c.knots[len(c.knots)-1], c.knots[0]
+	prev.progress--
+	next.progress++
+	if i > 0 {
+		prev = c.knots[i-1]
+	}
+	if i < len(c.knots) {
+		next = c.knots[i]
+	}
+	if next.progress == prev.progress {
+		return next.speed
+	}
+	t := (lapProgress - prev.progress) / (next.progress - prev.progress)
+	return prev.speed + t*(next.speed-prev.speed)
+}
+
+// Driving zones, see zoneOf
+const (
+	zoneBraking = iota
+	zoneFast
+	zoneSlow
+	zoneMedium
+	zoneCount
+)
+
+// zoneOf classifies a sample into a driving zone
+func zoneOf(lapProgress, speed float64) int {
+	switch {
+	case inBrakingZone(lapProgress): // Heavy braking zones
+		return zoneBraking
+	case speed > fastZoneSpeed: // Tunnel section
+		return zoneFast
+	case speed < slowZoneSpeed: // Slow corners
+		return zoneSlow
+	default: // Medium speed sections
+		return zoneMedium
+	}
+}
+
+// zone returns the inputs of a driving zone
+func (d *InputDistributions) zone(zone int) *ZoneInputs {
+	switch zone {
+	case zoneBraking:
+		return &d.Braking
+	case zoneFast:
+		return &d.Fast
+	case zoneSlow:
+		return &d.Slow
+	default:
+		return &d.Medium
+	}
+}
+
+// sample draws a tire temperature from the model
+func (m TireTempModel) sample(s *sampler, baseTemp float64, throttle, brake float64) float64 {
+	return baseTemp + s.normalRandom(0, m.Noise) + throttle*m.Throttle + brake*m.Brake
+}
+
+// mean returns the tire temperature the model draws around
+func (m TireTempModel) mean(baseTemp float64, throttle, brake float64) float64 {
+	return baseTemp + throttle*m.Throttle + brake*m.Brake
+}
+
+// ReadCalibration reads a calibration file written by WriteCalibration
+func ReadCalibration(r io.Reader) (Calibration, error) {
+	cal := DefaultCalibration()
+	if err := json.NewDecoder(r).Decode(&cal); err != nil {
+		return cal, fmt.Errorf("calibration: %w", err)
+	}
+	return cal, cal.Validate()
+}
+
+// Validate checks that the segments have speed profiles, that the noise
+// levels are not negative, that the time constant and acceleration limits
+// are positive and that every input range is ordered
+func (c *Calibration) Validate() error {
+	for _, seg := range c.Segments {
+		if len(seg.Speeds) == 0 || seg.End <= seg.Start {
+			return fmt.Errorf("calibration: segment %q has no speed profile", seg.Name)
+		}
+		for _, speed := range seg.Speeds {
+			if !(speed > 0) || math.IsInf(speed, 0) {
+				return fmt.Errorf("calibration: segment %q has speed %g", seg.Name, speed)
+			}
+		}
+	}
+	positive := []struct {
+		name  string
+		value float64
+	}{
+		{"speed_noise_tau", c.SpeedTau},
+		{"max_acceleration", c.MaxAccel},
+		{"max_braking", c.MaxBraking},
+	}
+	for _, v := range positive {
+		if !(v.value > 0) || math.IsInf(v.value, 0) {
+			return fmt.Errorf("calibration: %s %g must be positive", v.name, v.value)
+		}
+	}
+	noise := []struct {
+		name  string
+		value float64
+	}{
+		{"speed_noise", c.SpeedNoise},
+		{"rpm_noise", c.RPMNoise},
+		{"tire_temps.fl.noise", c.TireTemps.FL.Noise},
+		{"tire_temps.fr.noise", c.TireTemps.FR.Noise},
+		{"tire_temps.rl.noise", c.TireTemps.RL.Noise},
+		{"tire_temps.rr.noise", c.TireTemps.RR.Noise},
+		{"fuel_flow.noise", c.FuelFlow.Noise},
+	}
+	for _, v := range noise {
+		if !(v.value >= 0) || math.IsInf(v.value, 0) {
+			return fmt.Errorf("calibration: %s %g must not be negative", v.name, v.value)
+		}
+	}
+	ranges := []struct {
+		name  string
+		value Range
+	}{
+		{"inputs.braking.throttle", c.Inputs.Braking.Throttle},
+		{"inputs.braking.brake", c.Inputs.Braking.Brake},
+		{"inputs.fast.throttle", c.Inputs.Fast.Throttle},
+		{"inputs.fast.brake", c.Inputs.Fast.Brake},
+		{"inputs.slow.throttle", c.Inputs.Slow.Throttle},
+		{"inputs.slow.brake", c.Inputs.Slow.Brake},
+		{"inputs.medium.throttle", c.Inputs.Medium.Throttle},
+		{"inputs.medium.brake", c.Inputs.Medium.Brake},
+	}
+	for _, v := range ranges {
+		if !(v.value.Min >= 0 && v.value.Min <= v.value.Max) || math.IsInf(v.value.Max, 0) {
+			return fmt.Errorf("calibration: %s [%g, %g] must satisfy 0 <= min <= max", v.name, v.value.Min, v.value.Max)
+		}
+	}
+	return nil
+}
+
+// WriteCalibration writes c to w as indented JSON
+func WriteCalibration(w io.Writer, c Calibration) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c)
+}
+
+// referenceSample is one row of a reference session used for calibration
+type referenceSample struct {
+	time                   float64
+	lap                    int
+	distance, speed        float64
+	throttle, brake        float64
+	tireTemps              [4]float64
+	fuelFlow               float64
+	rpm                    float64
+	lapProgress, lapFactor float64 // filled in by Calibrate
+}
+
+// profilePointSpacing is the lap progress between calibrated speed profile points
+const profilePointSpacing = 0.005
+
+// Calibrate fits a Calibration to a reference telemetry CSV in the format
+// written by WriteTelemetryCSV. Lap progress is derived from distance, so
+// the reference may use any constant sample rate. Tire temperature offsets
+// are relative to the track temperature in params
+func Calibrate(r io.Reader, params *RaceParameters) (Calibration, error) {
+	samples, err := readReferenceCSV(r)
+	if err != nil {
+		return Calibration{}, err
+	}
+	if err := assignLapProgress(samples); err != nil {
+		return Calibration{}, err
+	}
+
+	cal := DefaultCalibration()
+	// The generator scales the profile by the fuel effect, starting with a full tank
+	startFuelEffect := fuelEffect(params, params.CurrentFuel)
+	dt := samplePeriod(samples)
+	cal.Segments = fitSpeedProfile(samples, startFuelEffect)
+	cal.buildKnots()
+	cal.SpeedNoise, cal.SpeedTau = fitSpeedNoise(samples, &cal, startFuelEffect, dt)
+	cal.MaxAccel, cal.MaxBraking = fitAccelerationLimits(samples, dt, params.MaxSpeed)
+	cal.Inputs = fitInputs(samples)
+	cal.TireTemps = TireTempModels{
+		FL: fitTireTemp(samples, 0, params.TrackTemp),
+		FR: fitTireTemp(samples, 1, params.TrackTemp),
+		RL: fitTireTemp(samples, 2, params.TrackTemp),
+		RR: fitTireTemp(samples, 3, params.TrackTemp),
+	}
+	cal.FuelFlow = fitFuelFlow(samples)
+	cal.RPMNoise = rpmNoise(samples)
+	return cal, nil
+}
+
+// assignLapProgress computes lap progress from distance and a per-lap pace
+// factor relative to the fastest lap, which removes tire and fuel trends
+// before the speed profile is estimated
+func assignLapProgress(samples []referenceSample) error {
+	type lapSpan struct {
+		first, last  int
+		start, speed float64
+	}
+	var laps []lapSpan
+	for i, sample := range samples {
+		if i == 0 || sample.lap != samples[i-1].lap {
+			laps = append(laps, lapSpan{first: i, start: sample.distance})
+		}
+		laps[len(laps)-1].last = i
+	}
+	if len(laps) < 2 {
+		return fmt.Errorf("calibration: reference needs at least two laps, found %d", len(laps))
+	}
+
+	// Lap length from the distance between consecutive lap starts
+	lengths := make([]float64, 0, len(laps)-1)
+	for i := 1; i < len(laps); i++ {
+		lengths = append(lengths, laps[i].start-laps[i-1].start)
+	}
+	lapLength := median(lengths)
+	if lapLength <= 0 {
+		return fmt.Errorf("calibration: distance does not increase between laps")
+	}
+
+	fastest := 0.0
+	for i := range laps {
+		sum := 0.0
+		for j := laps[i].first; j <= laps[i].last; j++ {
+			sum += samples[j].speed
+		}
+		laps[i].speed = sum / float64(laps[i].last-laps[i].first+1)
+		fastest = math.Max(fastest, laps[i].speed)
+	}
+
+	for _, lap := range laps {
+		for j := lap.first; j <= lap.last; j++ {
+			samples[j].lapProgress = clamp((samples[j].distance-lap.start)/lapLength, 0, 0.999999)
+			samples[j].lapFactor = fastest / lap.speed
+		}
+	}
+	return nil
+}
+
+// fitSpeedProfile estimates the speed profile of each track segment as the
+// mean pace-normalised speed in evenly spaced bins, divided by the fuel
+// effect the generator applies on the fastest lap
+func fitSpeedProfile(samples []referenceSample, fuelEffect float64) []SegmentProfile {
+	profiles := make([]SegmentProfile, len(monacoSegments))
+	sums := make([][]float64, len(monacoSegments))
+	counts := make([][]int, len(monacoSegments))
+	for i, seg := range monacoSegments {
+		start := segmentStart(i)
+		points := int(math.Max(2, math.Round((seg.End-start)/profilePointSpacing)))
+		profiles[i] = SegmentProfile{Name: seg.Name, Start: start, End: seg.End, Speeds: make([]float64, points)}
+		sums[i] = make([]float64, points)
+		counts[i] = make([]int, points)
+	}
+
+	for _, sample := range samples {
+		i := segmentIndex(sample.lapProgress)
+		p := &profiles[i]
+		bin := int((sample.lapProgress - p.Start) / (p.End - p.Start) * float64(len(p.Speeds)))
+		bin = clampInt(bin, 0, len(p.Speeds)-1)
+		sums[i][bin] += sample.speed * sample.lapFactor / fuelEffect
+		counts[i][bin]++
+	}
+
+	for i := range profiles {
+		for bin := range profiles[i].Speeds {
+			if counts[i][bin] > 0 {
+				profiles[i].Speeds[bin] = round3(sums[i][bin] / float64(counts[i][bin]))
+			} else {
+				// No reference data here, fall back to the built-in profile
+				p := profiles[i]
+				x := p.Start + (float64(bin)+0.5)*(p.End-p.Start)/float64(len(p.Speeds))
+				profiles[i].Speeds[bin] = round3(getMonacoSpeedProfile(x))
+			}
+		}
+	}
+	return profiles
+}
+
+// fitSpeedNoise estimates the Ornstein–Uhlenbeck speed noise from the
+// residuals around the calibrated profile: their standard deviation is the
+// stationary noise level and their lag-one autocorrelation gives the
+// correlation time
+func fitSpeedNoise(samples []referenceSample, cal *Calibration, fuelEffect, dt float64) (sigma, tau float64) {
+	defaults := DefaultCalibration()
+	residuals := make([]float64, len(samples))
+	for i, sample := range samples {
+		residuals[i] = sample.speed*sample.lapFactor/fuelEffect - cal.speedAt(sample.lapProgress)
+	}
+	if len(residuals) < 3 {
+		return defaults.SpeedNoise, defaults.SpeedTau
+	}
+	sigma = stddev(residuals)
+
+	var num, den float64
+	for i := 1; i < len(samples); i++ {
+		if samples[i].lap == samples[i-1].lap {
+			num += residuals[i] * residuals[i-1]
+			den += residuals[i-1] * residuals[i-1]
+		}
+	}
+	tau = defaults.SpeedTau
+	if den > 0 {
+		if rho := num / den; rho > 0 && rho < 1 {
+			tau = -dt / math.Log(rho)
+		}
+	}
+	return round3(sigma), round3(tau)
+}
+
+// fitAccelerationLimits estimates the traction and braking limits in g from
+// the 99th percentile of the reference's acceleration, normalised by the
+// speed dependence the solver applies
+func fitAccelerationLimits(samples []referenceSample, dt, maxSpeed float64) (accel, braking float64) {
+	unit := Calibration{MaxAccel: 1, MaxBraking: 1}
+	var accels, brakes []float64
+	for i := 1; i < len(samples); i++ {
+		if samples[i].lap != samples[i-1].lap {
+			continue
+		}
+		prev := samples[i-1].speed
+		a := (samples[i].speed - prev) / 3.6 / dt
+		switch {
+		case a > 0 && prev < 0.7*maxSpeed: // near top speed noise dominates
+			accels = append(accels, a/unit.accelerationLimit(prev, maxSpeed))
+		case a < 0:
+			brakes = append(brakes, -a/unit.brakingLimit(samples[i].speed, maxSpeed))
+		}
+	}
+
+	defaults := DefaultCalibration()
+	accel, braking = defaults.MaxAccel, defaults.MaxBraking
+	if len(accels) >= 10 {
+		accel = round3(percentile(accels, 0.99))
+	}
+	if len(brakes) >= 10 {
+		braking = round3(percentile(brakes, 0.99))
+	}
+	return accel, braking
+}
+
+// samplePeriod returns the median time between consecutive samples
+func samplePeriod(samples []referenceSample) float64 {
+	var periods []float64
+	for i := 1; i < len(samples); i++ {
+		if dt := samples[i].time - samples[i-1].time; dt > 0 {
+			periods = append(periods, dt)
+		}
+	}
+	if len(periods) == 0 {
+		return 0.1
+	}
+	return median(periods)
+}
+
+// fitInputs estimates throttle and brake ranges per zone from the 2nd and
+// 98th percentiles, which ignores occasional sensor spikes
+func fitInputs(samples []referenceSample) InputDistributions {
+	var throttle, brake [zoneCount][]float64
+	for _, sample := range samples {
+		zone := zoneOf(sample.lapProgress, sample.speed)
+		throttle[zone] = append(throttle[zone], sample.throttle)
+		brake[zone] = append(brake[zone], sample.brake)
+	}
+
+	inputs := DefaultCalibration().Inputs
+	for zone := 0; zone < zoneCount; zone++ {
+		if len(throttle[zone]) < 10 {
+			continue // too little data, keep the default
+		}
+		*inputs.zone(zone) = ZoneInputs{
+			Throttle: Range{round3(percentile(throttle[zone], 0.02)), round3(percentile(throttle[zone], 0.98))},
+			Brake:    Range{round3(percentile(brake[zone], 0.02)), round3(percentile(brake[zone], 0.98))},
+		}
+	}
+	return inputs
+}
+
+// fitTireTemp fits the linear tire temperature model of one corner by least squares
+func fitTireTemp(samples []referenceSample, corner int, trackTemp float64) TireTempModel {
+	rows := make([][]float64, len(samples))
+	targets := make([]float64, len(samples))
+	for i, sample := range samples {
+		rows[i] = []float64{1, float64(sample.lap), sample.throttle, sample.brake}
+		targets[i] = sample.tireTemps[corner]
+	}
+	coef, noise, ok := leastSquares(rows, targets)
+	if !ok {
+		defaults := DefaultCalibration().TireTemps
+		return [4]TireTempModel{defaults.FL, defaults.FR, defaults.RL, defaults.RR}[corner]
+	}
+	return TireTempModel{
+		Offset:   round3(coef[0] - trackTemp),
+		PerLap:   round3(coef[1]),
+		Throttle: round3(coef[2]),
+		Brake:    round3(coef[3]),
+		Noise:    round3(noise),
+	}
+}
+
+// fitFuelFlow fits the linear fuel flow model by least squares
+func fitFuelFlow(samples []referenceSample) FuelFlowModel {
+	rows := make([][]float64, len(samples))
+	targets := make([]float64, len(samples))
+	for i, sample := range samples {
+		rows[i] = []float64{1, sample.throttle}
+		targets[i] = sample.fuelFlow
+	}
+	coef, noise, ok := leastSquares(rows, targets)
+	if !ok {
+		return DefaultCalibration().FuelFlow
+	}
+	return FuelFlowModel{Base: round3(coef[0]), Throttle: round3(coef[1]), Noise: round3(noise)}
+}
+
+// rpmNoise returns the standard deviation of RPM around the speed-based RPM model
+func rpmNoise(samples []referenceSample) float64 {
+	residuals := make([]float64, 0, len(samples))
+	for _, sample := range samples {
+		// Samples at the rev limiter clamps carry no noise information
+		if sample.rpm <= 5000 || sample.rpm >= 15000 {
+			continue
+		}
+		residuals = append(residuals, sample.rpm-engineRPM(sample.speed))
+	}
+	if len(residuals) < 2 {
+		return DefaultCalibration().RPMNoise
+	}
+	return round3(stddev(residuals))
+}
+
+// leastSquares solves the normal equations for rows*coef ≈ targets and
+// returns the coefficients and the residual standard deviation
+func leastSquares(rows [][]float64, targets []float64) ([]float64, float64, bool) {
+	if len(rows) == 0 {
+		return nil, 0, false
+	}
+	n := len(rows[0])
+	if len(rows) <= n {
+		return nil, 0, false
+	}
+
+	// Augmented matrix [XᵀX | Xᵀy]
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = make([]float64, n+1)
+	}
+	for r, row := range rows {
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				m[i][j] += row[i] * row[j]
+			}
+			m[i][n] += row[i] * targets[r]
+		}
+	}
+
+	// Gaussian elimination with partial pivoting
+	for col := 0; col < n; col++ {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(m[r][col]) > math.Abs(m[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return nil, 0, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		for r := 0; r < n; r++ {
+			if r == col {
+				continue
+			}
+			f := m[r][col] / m[col][col]
+			for c := col; c <= n; c++ {
+				m[r][c] -= f * m[col][c]
+			}
+		}
+	}
+	coef := make([]float64, n)
+	for i := range coef {
+		coef[i] = m[i][n] / m[i][i]
+	}
+
+	residuals := make([]float64, len(rows))
+	for r, row := range rows {
+		predicted := 0.0
+		for i, x := range row {
+			predicted += coef[i] * x
+		}
+		residuals[r] = targets[r] - predicted
+	}
+	return coef, stddev(residuals), true
+}
+
+// stddev returns the population standard deviation of values
+func stddev(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance / float64(len(values)))
+}
+
+// percentile returns the q-th quantile (0-1) of values
+func percentile(values []float64, q float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted[int(q*float64(len(sorted)-1))]
+}
+
+// median returns the median of values
+func median(values []float64) float64 {
+	return percentile(values, 0.5)
+}
+
+// Columns read from a reference telemetry CSV
+var referenceColumns = []string{
+	"time", "lap", "distance", "speed", "throttle", "brake_pressure",
+	"tire_temp_fl", "tire_temp_fr", "tire_temp_rl", "tire_temp_rr",
+	"fuel_flow", "engine_rpm",
+}
+
+// readReferenceCSV reads the calibration columns of a telemetry CSV.
+// Columns are located by header name, so extra channels are ignored
+func readReferenceCSV(r io.Reader) ([]referenceSample, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("calibration: reading header: %w", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[name] = i
+	}
+	cols := make([]int, len(referenceColumns))
+	for i, name := range referenceColumns {
+		col, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("calibration: reference is missing column %q", name)
+		}
+		cols[i] = col
+	}
+
+	var samples []referenceSample
+	values := make([]float64, len(cols))
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("calibration: %w", err)
+		}
+		for i, col := range cols {
+			values[i], err = strconv.ParseFloat(record[col], 64)
+			if err != nil {
+				return nil, fmt.Errorf("calibration line %d: %s: %q is not a number", line, referenceColumns[i], record[col])
+			}
+		}
+		samples = append(samples, referenceSample{
+			time:      values[0],
+			lap:       int(values[1]),
+			distance:  values[2],
+			speed:     values[3],
+			throttle:  values[4],
+			brake:     values[5],
+			tireTemps: [4]float64{values[6], values[7], values[8], values[9]},
+			fuelFlow:  values[10],
+			rpm:       values[11],
+		})
+	}
+	return samples, nil
+}
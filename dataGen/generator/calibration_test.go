@@ -0,0 +1,84 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestCalibrateRoundTrip generates telemetry from a known calibration and
+// checks that Calibrate recovers the constants the telemetry shows directly
+func TestCalibrateRoundTrip(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Laps = 8
+	opts.PitLaps = []int{}
+	opts.DamageEvents = []DamageEvent{}
+	opts.EngineFailures = []EngineFailure{}
+	want := DefaultCalibration()
+	want.MaxAccel, want.MaxBraking = 1.1, 2.2
+	want.RPMNoise = 250
+	want.FuelFlow = FuelFlowModel{Base: 20, Throttle: 0.9, Noise: 3}
+	want.TireTemps.FL.Throttle = 0.25
+	want.Inputs.Slow.Throttle = Range{35, 55}
+	opts.Calibration = &want
+	result, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reference bytes.Buffer
+	if err := WriteTelemetryCSV(&reference, result.Telemetry); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Calibrate(&reference, opts.parameters())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Validate(); err != nil {
+		t.Errorf("fitted calibration invalid: %v", err)
+	}
+
+	constants := []struct {
+		name           string
+		got, want, tol float64
+	}{
+		{"max_acceleration", got.MaxAccel, want.MaxAccel, 0.05 * want.MaxAccel},
+		{"max_braking", got.MaxBraking, want.MaxBraking, 0.05 * want.MaxBraking},
+		{"rpm_noise", got.RPMNoise, want.RPMNoise, 0.1 * want.RPMNoise},
+		{"fuel_flow.base", got.FuelFlow.Base, want.FuelFlow.Base, 0.5},
+		{"fuel_flow.throttle", got.FuelFlow.Throttle, want.FuelFlow.Throttle, 0.02},
+		{"fuel_flow.noise", got.FuelFlow.Noise, want.FuelFlow.Noise, 0.3},
+		{"tire_temps.fl.throttle", got.TireTemps.FL.Throttle, want.TireTemps.FL.Throttle, 0.02},
+		{"tire_temps.rr.throttle", got.TireTemps.RR.Throttle, want.TireTemps.RR.Throttle, 0.02},
+		{"tire_temps.fl.brake", got.TireTemps.FL.Brake, want.TireTemps.FL.Brake, 0.02},
+		{"inputs.slow.throttle.min", got.Inputs.Slow.Throttle.Min, want.Inputs.Slow.Throttle.Min, 2},
+		{"inputs.slow.throttle.max", got.Inputs.Slow.Throttle.Max, want.Inputs.Slow.Throttle.Max, 2},
+		{"inputs.braking.brake.max", got.Inputs.Braking.Brake.Max, want.Inputs.Braking.Brake.Max, 3},
+	}
+	for _, c := range constants {
+		if math.Abs(c.got-c.want) > c.tol {
+			t.Errorf("%s fitted as %.3f, generated with %.3f", c.name, c.got, c.want)
+		}
+	}
+}
+
+// TestReadCalibrationValidates checks that a calibration file with
+// constants the telemetry model cannot run with is rejected
+func TestReadCalibrationValidates(t *testing.T) {
+	for _, file := range []string{
+		`{"max_acceleration": 0}`,
+		`{"max_braking": -1}`,
+		`{"speed_noise_tau": 0}`,
+		`{"speed_noise": -0.5}`,
+		`{"inputs": {"fast": {"throttle": {"min": 90, "max": 80}}}}`,
+		`{"segments": [{"name": "Sainte Devote", "start": 0, "end": 0.1, "speeds": []}]}`,
+	} {
+		if _, err := ReadCalibration(strings.NewReader(file)); err == nil {
+			t.Errorf("calibration %s accepted", file)
+		}
+	}
+	if _, err := ReadCalibration(strings.NewReader(`{"max_braking": 3}`)); err != nil {
+		t.Errorf("valid calibration rejected: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// Compound describes how a tire compound performs over a stint. Lap time
+// effects are fractions of the lap time, so they scale with the track
+type Compound struct {
+	PaceOffset   float64 `json:"pace_offset"`   // lap time fraction against the reference, negative is faster
+	TempOffset   float64 `json:"temp_offset"`   // °C the compound runs above the calibrated tire temperatures
+	WarmUpTemp   float64 `json:"warm_up_temp"`  // °C a fresh set runs below its working temperature on its first lap
+	WarmUpLaps   float64 `json:"warm_up_laps"`  // laps for the warm-up deficit to fall by 1/e
+	OptimalTemp  Range   `json:"optimal_temp"`  // °C window giving full grip
+	TempPenalty  float64 `json:"temp_penalty"`  // lap time fraction lost per °C outside the window
+	WearRate     float64 `json:"wear_rate"`     // multiplier on the race tire wear rate
+	CliffLaps    float64 `json:"cliff_laps"`    // laps of wear after which grip falls away
+	CliffPenalty float64 `json:"cliff_penalty"` // lap time fraction lost per lap past the cliff
+}
+
+// trackCompounds holds the compounds raced at each known track by track name
+var trackCompounds = map[string]map[string]Compound{
+	// The softest compounds of the range: low wear and little heat from the
+	// slow corners, so the harder sets struggle to switch on
+	"Monaco": {
+		"Soft": {
+			PaceOffset: -0.009, TempOffset: 4, WarmUpTemp: 10, WarmUpLaps: 0.7,
+			OptimalTemp: Range{92, 118}, TempPenalty: 0.0004,
+			WearRate: 1.35, CliffLaps: 24, CliffPenalty: 0.02,
+		},
+		"Medium": {
+			PaceOffset: 0, TempOffset: 0, WarmUpTemp: 14, WarmUpLaps: 1.2,
+			OptimalTemp: Range{96, 122}, TempPenalty: 0.0004,
+			WearRate: 1, CliffLaps: 36, CliffPenalty: 0.015,
+		},
+		"Hard": {
+			PaceOffset: 0.006, TempOffset: -4, WarmUpTemp: 18, WarmUpLaps: 2,
+			OptimalTemp: Range{100, 126}, TempPenalty: 0.0004,
+			WearRate: 0.7, CliffLaps: 50, CliffPenalty: 0.012,
+		},
+	},
+}
+
+// Typical driver inputs used to place a lap's tire temperature against the
+// optimal window
+const (
+	typicalThrottle = 50 // %
+	typicalBrake    = 15 // bar
+)
+
+// ReadCompounds reads a set of compounds by name from JSON
+func ReadCompounds(r io.Reader) (map[string]Compound, error) {
+	var compounds map[string]Compound
+	if err := json.NewDecoder(r).Decode(&compounds); err != nil {
+		return nil, fmt.Errorf("compounds: %w", err)
+	}
+	return compounds, validateCompounds(compounds)
+}
+
+// validateCompounds checks that every compound of the set is usable
+func validateCompounds(compounds map[string]Compound) error {
+	if len(compounds) == 0 {
+		return fmt.Errorf("compounds: no compounds")
+	}
+	for name, c := range compounds {
+		switch {
+		case c.WarmUpLaps <= 0 || c.WarmUpTemp < 0:
+			return fmt.Errorf("compound %s: warm-up needs positive laps and a non-negative temperature", name)
+		case c.OptimalTemp.Min >= c.OptimalTemp.Max:
+			return fmt.Errorf("compound %s: optimal temperature window %g-%g is empty", name, c.OptimalTemp.Min, c.OptimalTemp.Max)
+		case c.WearRate < 0 || c.CliffLaps < 0 || c.CliffPenalty < 0 || c.TempPenalty < 0:
+			return fmt.Errorf("compound %s: wear, cliff and temperature penalties must not be negative", name)
+		case c.PaceOffset <= -1:
+			return fmt.Errorf("compound %s: pace offset %g must be above -1", name, c.PaceOffset)
+		}
+	}
+	return nil
+}
+
+// compoundNames returns the names of compounds from fastest to slowest
+func compoundNames(compounds map[string]Compound) []string {
+	names := make([]string, 0, len(compounds))
+	for name := range compounds {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := compounds[names[i]], compounds[names[j]]
+		if a.PaceOffset != b.PaceOffset {
+			return a.PaceOffset < b.PaceOffset
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// tireModel combines the race parameter wear model, the calibrated tire
+// temperatures and the compounds into the lap time and temperature of a
+// set of tires
+type tireModel struct {
+	params    *RaceParameters
+	temps     TireTempModels
+	compounds map[string]Compound
+}
+
+// tireModel returns the tire model of the run
+func (o Options) tireModel() tireModel {
+	return tireModel{
+		params:    o.parameters(),
+		temps:     o.calibration().TireTemps,
+		compounds: o.compounds(),
+	}
+}
+
+// tempShift returns the °C the compound runs above the calibrated tire
+// temperatures after tireLaps laps on the set, including the warm-up deficit
+// of a fresh set
+func (c Compound) tempShift(tireLaps int) float64 {
+	return c.TempOffset - c.WarmUpTemp*math.Exp(-float64(tireLaps)/c.WarmUpLaps)
+}
+
+// lap returns the lap time multiplier and the tire temperature shift of a
+// lap on compound with tireLaps laps on the set and stintLap laps into the
+// current stint, relative to fresh tires of the reference compound in their
+// window. Wear follows the race parameter grip model scaled by the
+// compound's wear rate, and falls away past the cliff
+func (t tireModel) lap(compound string, driver Driver, tireLaps, stintLap int) (factor, tempShift float64) {
+	c := t.compounds[compound]
+	wearLaps := float64(tireLaps) * driver.tireWearFactor()
+	tempShift = c.tempShift(tireLaps)
+
+	// Running temperature at typical inputs, averaged across the corners
+	var temp float64
+	for _, m := range []TireTempModel{t.temps.FL, t.temps.FR, t.temps.RL, t.temps.RR} {
+		temp += (m.Offset + m.PerLap*float64(stintLap) + m.Throttle*typicalThrottle + m.Brake*typicalBrake) / 4
+	}
+	temp = clamp(t.params.TrackTemp+driver.tireTempOffset()+temp+tempShift, 80, 140)
+	outside := math.Max(c.OptimalTemp.Min-temp, 0) + math.Max(temp-c.OptimalTemp.Max, 0)
+
+	factor = (1 + c.PaceOffset) * t.params.TireLapTimeFactor(wearLaps*c.WearRate)
+	factor *= 1 + c.TempPenalty*outside
+	factor *= 1 + c.CliffPenalty*math.Max(wearLaps-c.CliffLaps, 0)
+	return factor, tempShift
+}
+
+// lastLapFactor returns the lap time multiplier of the tires behind a car's
+// last lap in the standings, on a set tireAge laps old
+func (t tireModel) lastLapFactor(compound string, driver Driver, tireAge int) float64 {
+	factor, _ := t.lap(compound, driver, tireAge, 1)
+	return factor
+}
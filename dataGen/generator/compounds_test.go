@@ -0,0 +1,126 @@
+package generator
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestCompoundCharacter checks the Monaco compounds trade pace for life:
+// softer sets are faster fresh, warm up sooner, wear faster and reach their
+// cliff earlier
+func TestCompoundCharacter(t *testing.T) {
+	tires := DefaultOptions().tireModel()
+	driver := DefaultDriver()
+	lap := func(compound string, tireLaps int) float64 {
+		factor, _ := tires.lap(compound, driver, tireLaps, 3)
+		return factor
+	}
+
+	if soft, hard := lap("Soft", 1), lap("Hard", 1); soft >= hard {
+		t.Errorf("soft tires lap at %.4f on their first flying lap, hard at %.4f", soft, hard)
+	}
+	soft, hard := tires.compounds["Soft"], tires.compounds["Hard"]
+	if soft.tempShift(1)-soft.TempOffset <= hard.tempShift(1)-hard.TempOffset {
+		t.Errorf("soft tires are %.1f°C short after a lap, hard %.1f°C",
+			soft.TempOffset-soft.tempShift(1), hard.TempOffset-hard.tempShift(1))
+	}
+	softWear := lap("Soft", 20) - lap("Soft", 5)
+	hardWear := lap("Hard", 20) - lap("Hard", 5)
+	if softWear <= hardWear {
+		t.Errorf("soft tires lose %.4f over 15 laps, hard %.4f", softWear, hardWear)
+	}
+
+	// Past the cliff each lap costs more than the lap before it did
+	cliff := int(tires.compounds["Soft"].CliffLaps)
+	before := lap("Soft", cliff-1) - lap("Soft", cliff-2)
+	after := lap("Soft", cliff+2) - lap("Soft", cliff+1)
+	if after-before < tires.compounds["Soft"].CliffPenalty/2 {
+		t.Errorf("soft tires lose %.4f a lap before the cliff and %.4f after", before, after)
+	}
+}
+
+// TestPitCompoundsFitted checks that stops fit the requested compounds and
+// that the rest change compound
+func TestPitCompoundsFitted(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Laps = 30
+	opts.PitLaps = []int{5, 12, 20}
+	opts.PitCompounds = []string{"Hard"}
+	stops := ourCar(opts, nil).stops
+	if stops[0].Compound != "Hard" {
+		t.Errorf("first stop fits %s, want Hard", stops[0].Compound)
+	}
+	for i := 1; i < len(stops); i++ {
+		if stops[i].Compound == stops[i-1].Compound {
+			t.Errorf("stop %d refits %s", i+1, stops[i].Compound)
+		}
+	}
+
+	opts.PitCompounds = []string{"Ultra"}
+	if _, err := GenerateSession(context.Background(), opts); err == nil {
+		t.Error("unknown pit compound accepted")
+	}
+}
+
+// TestLapTimesFollowCompound checks that our car's laps take as long in the
+// telemetry as in the timing feed, and that after a stop they are quicker on
+// Softs than on Hards
+func TestLapTimesFollowCompound(t *testing.T) {
+	total := map[string]float64{}
+	for _, compound := range []string{"Soft", "Hard"} {
+		opts := DefaultOptions()
+		opts.Laps = 6
+		opts.PitLaps = []int{2}
+		opts.PitCompounds = []string{compound}
+		opts.DamageEvents = []DamageEvent{}
+		opts.EngineFailures = []EngineFailure{}
+		result, err := Generate(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d := result.Telemetry
+		samples := make(map[int]int)
+		for _, lap := range d.Lap {
+			samples[lap]++
+		}
+		for _, msg := range result.TimingFeed {
+			data, ok := msg.Data.(LapCompletedData)
+			if !ok || data.CarNumber != opts.carNumber() || data.Lap == 2 || data.Lap == 3 {
+				continue // The in- and out-laps run through the pit lane
+			}
+			lapTime := float64(samples[data.Lap]) / d.SampleRate
+			if math.Abs(lapTime-data.LapTime) > 1/d.SampleRate+1e-9 {
+				t.Errorf("%s: lap %d takes %.1fs in the telemetry, %.3fs in the timing feed", compound, data.Lap, lapTime, data.LapTime)
+			}
+			if data.Lap > 3 {
+				total[compound] += lapTime
+			}
+		}
+	}
+	if total["Soft"] >= total["Hard"] {
+		t.Errorf("laps after the stop take %.1fs on Softs, %.1fs on Hards", total["Soft"], total["Hard"])
+	}
+}
+
+// TestCustomStartingCompound checks that a race can start on a compound
+// defined only in a custom compound set, and on none outside the set in use
+func TestCustomStartingCompound(t *testing.T) {
+	params, err := ReadRaceParametersCSV(strings.NewReader("parameter,value\ntire_compound,Wet\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions()
+	opts.Laps = 2
+	opts.Parameters = &params
+	if _, err := GenerateSession(context.Background(), opts); err == nil {
+		t.Error("Wet start accepted without a Wet compound")
+	}
+
+	opts.Compounds = map[string]Compound{"Wet": trackCompounds["Monaco"]["Hard"], "Medium": trackCompounds["Monaco"]["Medium"]}
+	if _, err := GenerateSession(context.Background(), opts); err != nil {
+		t.Errorf("Wet start rejected with a Wet compound: %v", err)
+	}
+}
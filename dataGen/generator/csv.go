@@ -0,0 +1,266 @@
+package generator
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Telemetry CSV header
+var telemetryHeader = []string{
+	"time", "lap", "distance", "speed", "throttle", "brake_pressure",
+	"tire_temp_fl", "tire_temp_fr", "tire_temp_rl", "tire_temp_rr",
+	"fuel_flow", "engine_rpm", "drs_active", "battery_deployment",
+	"gear", "steering_angle", "pit_limiter", "aero_damage",
+	"brake_temp_fl", "brake_temp_fr", "brake_temp_rl", "brake_temp_rr", "brake_bias",
+	"wheel_speed_fl", "wheel_speed_fr", "wheel_speed_rl", "wheel_speed_rr",
+	"slip_fl", "slip_fr", "slip_rl", "slip_rr", "slip_angle_front", "slip_angle_rear",
+	"oil_temp", "water_temp", "oil_pressure", "turbo_speed", "clutch",
+}
+
+// TelemetryCSVWriter streams telemetry data to CSV, one chunk at a time.
+// Rows are formatted straight into a reused buffer, as telemetry at high
+// sample rates is written millions of rows at a time
+type TelemetryCSVWriter struct {
+	writer  *bufio.Writer
+	row     []byte
+	withCar bool // rows start with a car_number column
+}
+
+// NewTelemetryCSVWriter creates a TelemetryCSVWriter and writes the header
+func NewTelemetryCSVWriter(w io.Writer) (*TelemetryCSVWriter, error) {
+	return newTelemetryCSVWriter(w, telemetryHeader, false)
+}
+
+// NewGridTelemetryCSVWriter creates a TelemetryCSVWriter for the telemetry of
+// several cars in one file, with a leading car_number column
+func NewGridTelemetryCSVWriter(w io.Writer) (*TelemetryCSVWriter, error) {
+	return newTelemetryCSVWriter(w, append([]string{"car_number"}, telemetryHeader...), true)
+}
+
+// newTelemetryCSVWriter creates a TelemetryCSVWriter and writes header
+func newTelemetryCSVWriter(w io.Writer, header []string, withCar bool) (*TelemetryCSVWriter, error) {
+	writer := bufio.NewWriterSize(w, 64*1024)
+	if _, err := writer.WriteString(strings.Join(header, ",") + "\n"); err != nil {
+		return nil, err
+	}
+	return &TelemetryCSVWriter{writer: writer, row: make([]byte, 0, 256), withCar: withCar}, nil
+}
+
+// Write appends all samples of data as CSV rows
+func (t *TelemetryCSVWriter) Write(data *TelemetryData) error {
+	return t.write(-1, data)
+}
+
+// WriteCar appends all samples of one car's data as CSV rows. The car number
+// is only written by writers created with NewGridTelemetryCSVWriter
+func (t *TelemetryCSVWriter) WriteCar(carNumber int, data *TelemetryData) error {
+	if !t.withCar {
+		return t.write(-1, data)
+	}
+	return t.write(carNumber, data)
+}
+
+// write appends each sample of data as a row, led by carNumber unless it is
+// negative
+func (t *TelemetryCSVWriter) write(carNumber int, data *TelemetryData) error {
+	timeDecimals := timeDecimals(data.SampleRate)
+	for i := 0; i < len(data.Time); i++ {
+		row := t.row[:0]
+		if carNumber >= 0 {
+			row = strconv.AppendInt(row, int64(carNumber), 10)
+			row = append(row, ',')
+		}
+		row = strconv.AppendFloat(row, data.Time[i], 'f', timeDecimals, 64)
+		row = appendInt(row, data.Lap[i])
+		row = appendFloat(row, data.Distance[i], 3)
+		row = appendFloat(row, data.Speed[i], 1)
+		row = appendFloat(row, data.Throttle[i], 1)
+		row = appendFloat(row, data.BrakePressure[i], 1)
+		row = appendFloat(row, data.TireTempFL[i], 1)
+		row = appendFloat(row, data.TireTempFR[i], 1)
+		row = appendFloat(row, data.TireTempRL[i], 1)
+		row = appendFloat(row, data.TireTempRR[i], 1)
+		row = appendFloat(row, data.FuelFlow[i], 1)
+		row = appendInt(row, data.EngineRPM[i])
+		row = appendInt(row, data.DRSActive[i])
+		row = appendFloat(row, data.BatteryDeployment[i], 1)
+		row = appendInt(row, data.Gear[i])
+		row = appendFloat(row, data.SteeringAngle[i], 1)
+		row = appendInt(row, data.PitLimiter[i])
+		row = appendFloat(row, data.AeroDamage[i], 3)
+		row = appendFloat(row, data.BrakeTempFL[i], 1)
+		row = appendFloat(row, data.BrakeTempFR[i], 1)
+		row = appendFloat(row, data.BrakeTempRL[i], 1)
+		row = appendFloat(row, data.BrakeTempRR[i], 1)
+		row = appendFloat(row, data.BrakeBias[i], 1)
+		row = appendFloat(row, data.WheelSpeedFL[i], 1)
+		row = appendFloat(row, data.WheelSpeedFR[i], 1)
+		row = appendFloat(row, data.WheelSpeedRL[i], 1)
+		row = appendFloat(row, data.WheelSpeedRR[i], 1)
+		row = appendFloat(row, data.SlipFL[i], 1)
+		row = appendFloat(row, data.SlipFR[i], 1)
+		row = appendFloat(row, data.SlipRL[i], 1)
+		row = appendFloat(row, data.SlipRR[i], 1)
+		row = appendFloat(row, data.SlipAngleFront[i], 2)
+		row = appendFloat(row, data.SlipAngleRear[i], 2)
+		row = appendFloat(row, data.OilTemp[i], 1)
+		row = appendFloat(row, data.WaterTemp[i], 1)
+		row = appendFloat(row, data.OilPressure[i], 2)
+		row = appendInt(row, data.TurboSpeed[i])
+		row = appendFloat(row, data.Clutch[i], 1)
+		row = append(row, '\n')
+		t.row = row
+		if _, err := t.writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// appendFloat appends a comma and f with the given number of decimals
+func appendFloat(row []byte, f float64, decimals int) []byte {
+	return strconv.AppendFloat(append(row, ','), f, 'f', decimals, 64)
+}
+
+// appendInt appends a comma and i
+func appendInt(row []byte, i int) []byte {
+	return strconv.AppendInt(append(row, ','), int64(i), 10)
+}
+
+// Flush writes any buffered rows to the underlying writer
+func (t *TelemetryCSVWriter) Flush() error {
+	return t.writer.Flush()
+}
+
+// WriteTelemetryCSV writes telemetry data as CSV to w
+func WriteTelemetryCSV(w io.Writer, data *TelemetryData) error {
+	writer, err := NewTelemetryCSVWriter(w)
+	if err != nil {
+		return err
+	}
+	if err := writer.Write(data); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// WriteLapSummaryCSV writes lap summaries as CSV to w
+func WriteLapSummaryCSV(w io.Writer, laps []LapSummary) error {
+	writer := csv.NewWriter(w)
+
+	// Write header
+	header := []string{
+		"lap", "lap_type", "lap_time", "sector_1", "sector_2", "sector_3",
+		"top_speed", "min_corner_speed",
+		"avg_tire_temp_fl", "avg_tire_temp_fr", "avg_tire_temp_rl", "avg_tire_temp_rr",
+		"fuel_used", "ers_deployed", "tire_compound", "tire_age",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	// Write lap rows
+	for _, lap := range laps {
+		row := []string{
+			strconv.Itoa(lap.Lap),
+			lap.LapType,
+			fmt.Sprintf("%.3f", lap.LapTime),
+			fmt.Sprintf("%.3f", lap.SectorTimes[0]),
+			fmt.Sprintf("%.3f", lap.SectorTimes[1]),
+			fmt.Sprintf("%.3f", lap.SectorTimes[2]),
+			fmt.Sprintf("%.1f", lap.TopSpeed),
+			fmt.Sprintf("%.1f", lap.MinCornerSpeed),
+			fmt.Sprintf("%.1f", lap.TireTempFL),
+			fmt.Sprintf("%.1f", lap.TireTempFR),
+			fmt.Sprintf("%.1f", lap.TireTempRL),
+			fmt.Sprintf("%.1f", lap.TireTempRR),
+			fmt.Sprintf("%.3f", lap.FuelUsed),
+			fmt.Sprintf("%.3f", lap.ERSDeployed),
+			lap.TireCompound,
+			strconv.Itoa(lap.TireAge),
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteRaceParametersCSV writes race parameters as CSV to w. The type column
+// tells readers how to parse each value
+func WriteRaceParametersCSV(w io.Writer, params RaceParameters) error {
+	writer := csv.NewWriter(w)
+
+	// Write header
+	header := []string{"parameter", "value", "unit", "description", "type"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	// Write parameter rows
+	for _, spec := range parameterSchema {
+		row := []string{
+			spec.Name,
+			params.formatValue(spec),
+			string(spec.Unit),
+			spec.Description,
+			string(spec.Type),
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteCompetitorCSV writes competitor data as CSV to w. Our car is a row
+// like any other, flagged in the our_car column
+func WriteCompetitorCSV(w io.Writer, competitors []Competitor) error {
+	writer := csv.NewWriter(w)
+
+	// Write header
+	header := []string{
+		"car_number", "position", "gap_to_leader", "last_lap_time",
+		"tire_compound", "pit_stops", "estimated_speed", "fuel_load_estimate",
+		"tire_age", "distance_to_our_car", "driver_profile",
+		"interval", "laps_down", "our_car",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	// Write competitor rows
+	for _, comp := range competitors {
+		row := []string{
+			strconv.Itoa(comp.CarNumber),
+			strconv.Itoa(comp.Position),
+			fmt.Sprintf("%.2f", comp.GapToLeader),
+			fmt.Sprintf("%.3f", comp.LastLapTime),
+			comp.TireCompound,
+			strconv.Itoa(comp.PitStops),
+			fmt.Sprintf("%.1f", comp.EstimatedSpeed),
+			fmt.Sprintf("%.1f", comp.FuelLoadEstimate),
+			strconv.Itoa(comp.TireAge),
+			fmt.Sprintf("%.1f", comp.DistanceToOurCar),
+			comp.Driver,
+			fmt.Sprintf("%.2f", comp.Interval),
+			strconv.Itoa(comp.LapsDown),
+			strconv.FormatBool(comp.OurCar),
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,27 @@
+package generator
+
+import (
+	"io"
+	"testing"
+)
+
+// TestTelemetryCSVWriterAllocations guards the row formatting path: once the
+// writer exists, writing a lap must not allocate per row
+func TestTelemetryCSVWriterAllocations(t *testing.T) {
+	opts := DefaultOptions()
+	model := newTelemetryModel(opts, ourCar(opts, nil))
+	lap := model.generateLap(newSampler(2), model.newPlanner(newSampler(1)).next())
+
+	writer, err := NewTelemetryCSVWriter(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		if err := writer.Write(lap); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 0 {
+		t.Errorf("writing %d rows allocated %v times, want 0", lap.Len(), allocs)
+	}
+}
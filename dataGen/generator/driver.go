@@ -0,0 +1,207 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// Driver describes a driving style. All traits range from 0 to 1; braking,
+// throttle and tire management at their 0.5 midpoint leave the base
+// telemetry model unchanged
+type Driver struct {
+	Name               string  `json:"name"`
+	BrakingAggression  float64 `json:"braking_aggression"`  // later, harder braking
+	ThrottleRate       float64 `json:"throttle_rate"`       // how quickly throttle is applied on exit
+	Consistency        float64 `json:"consistency"`         // 1 means identical laps
+	MistakeProbability float64 `json:"mistake_probability"` // chance of a mistake per corner per lap
+	TireManagement     float64 `json:"tire_management"`     // slows tire wear and heat build-up
+}
+
+// driverProfiles holds the built-in driver profiles by name
+var driverProfiles = map[string]Driver{
+	"balanced":   {"balanced", 0.5, 0.5, 0.8, 0.01, 0.5},
+	"aggressive": {"aggressive", 0.9, 0.85, 0.7, 0.025, 0.25},
+	"smooth":     {"smooth", 0.35, 0.4, 0.9, 0.005, 0.85},
+	"rookie":     {"rookie", 0.6, 0.65, 0.5, 0.05, 0.3},
+	"veteran":    {"veteran", 0.55, 0.5, 0.95, 0.004, 0.75},
+}
+
+// Mistake kinds
+const (
+	mistakeLockUp     = "lock_up"
+	mistakeMissedApex = "missed_apex"
+)
+
+// mistakeCornerWidth is the lap progress either side of the apex affected by a mistake
+const mistakeCornerWidth = 0.012
+
+// DefaultDriver returns the balanced driver profile
+func DefaultDriver() Driver {
+	return driverProfiles["balanced"]
+}
+
+// DriverProfile returns the built-in profile called name
+func DriverProfile(name string) (Driver, bool) {
+	d, ok := driverProfiles[name]
+	return d, ok
+}
+
+// DriverProfileNames returns the names of the built-in profiles in sorted order
+func DriverProfileNames() []string {
+	names := make([]string, 0, len(driverProfiles))
+	for name := range driverProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// competitorDriver returns the driving style of a competitor: ours for our
+// car, and the balanced profile for a car with no profile or one that is
+// not built in
+func competitorDriver(opts Options, c Competitor) Driver {
+	if c.OurCar {
+		return opts.driver()
+	}
+	if d, ok := DriverProfile(c.Driver); ok {
+		return d
+	}
+	return DefaultDriver()
+}
+
+// ReadDriver reads a driver profile from JSON. Traits missing from the input
+// keep their balanced value
+func ReadDriver(r io.Reader) (Driver, error) {
+	d := DefaultDriver()
+	if err := json.NewDecoder(r).Decode(&d); err != nil {
+		return d, fmt.Errorf("driver: %w", err)
+	}
+	return d, d.Validate()
+}
+
+// Validate checks that every trait is within [0, 1]
+func (d Driver) Validate() error {
+	traits := []struct {
+		name  string
+		value float64
+	}{
+		{"braking_aggression", d.BrakingAggression},
+		{"throttle_rate", d.ThrottleRate},
+		{"consistency", d.Consistency},
+		{"mistake_probability", d.MistakeProbability},
+		{"tire_management", d.TireManagement},
+	}
+	for _, t := range traits {
+		if t.value < 0 || t.value > 1 {
+			return fmt.Errorf("driver %s: %s %g outside range [0, 1]", d.Name, t.name, t.value)
+		}
+	}
+	return nil
+}
+
+// brakingFactor scales braking force and brake pressure
+func (d Driver) brakingFactor() float64 {
+	return 0.85 + 0.3*d.BrakingAggression
+}
+
+// throttleFactor scales the usable traction and throttle application
+func (d Driver) throttleFactor() float64 {
+	return 0.85 + 0.3*d.ThrottleRate
+}
+
+// tireWearFactor scales the laps of wear put into the tires each lap
+func (d Driver) tireWearFactor() float64 {
+	return 1.15 - 0.3*d.TireManagement
+}
+
+// tireTempOffset is the change in tire temperature from the driver's style in °C
+func (d Driver) tireTempOffset() float64 {
+	return (0.5 - d.TireManagement) * 8
+}
+
+// lapTimeSigma returns the lap-to-lap standard deviation in seconds of a
+// lap around lapTime seconds
+func (d Driver) lapTimeSigma(lapTime float64) float64 {
+	return 0.2 + lapTime*(1-d.Consistency)*0.01
+}
+
+// paceSigma returns the lap-to-lap standard deviation of the pace multiplier
+func (d Driver) paceSigma() float64 {
+	return (1 - d.Consistency) * 0.01
+}
+
+// adjust returns cal with the driver's braking and throttle style applied
+func (d Driver) adjust(cal Calibration) Calibration {
+	braking, throttle := d.brakingFactor(), d.throttleFactor()
+	cal.MaxBraking *= braking
+	cal.MaxAccel *= throttle
+	for zone := 0; zone < zoneCount; zone++ {
+		inputs := cal.Inputs.zone(zone)
+		inputs.Brake = Range{inputs.Brake.Min * braking, inputs.Brake.Max * braking}
+		inputs.Throttle = Range{
+			math.Min(inputs.Throttle.Min*throttle, 100),
+			math.Min(inputs.Throttle.Max*throttle, 100),
+		}
+	}
+	return cal
+}
+
+// mistake is a driver error at one corner on one lap
+type mistake struct {
+	kind  string
+	apex  float64 // lap progress of the corner apex
+	wheel int     // wheel that locks, wheelFL to wheelRR
+	depth float64 // fraction of its speed the locked wheel loses at worst
+}
+
+// drawMistakes decides which corners the driver gets wrong this lap. Half
+// of a balanced driver's mistakes are lock-ups, made lockUpScale times as
+// likely by the driver's braking, the tires and the brake bias
+func (d Driver) drawMistakes(s *sampler, lockUpScale float64) []mistake {
+	var mistakes []mistake
+	lockUp := d.MistakeProbability / 2 * lockUpScale
+	missedApex := d.MistakeProbability / 2
+	for _, c := range monacoCorners {
+		u := s.float64()
+		switch {
+		case u < lockUp:
+			// The unloaded inside wheel locks, usually at the front
+			wheel := wheelFL
+			if c.Direction == turnRight {
+				wheel = wheelFR
+			}
+			if s.float64() < 0.2 {
+				wheel += wheelRL
+			}
+			mistakes = append(mistakes, mistake{mistakeLockUp, c.Apex, wheel, s.uniformRandom(0.25, 0.6)})
+		case u < lockUp+missedApex:
+			mistakes = append(mistakes, mistake{kind: mistakeMissedApex, apex: c.Apex})
+		}
+	}
+	return mistakes
+}
+
+// mistakeAt returns the mistake affecting lapProgress, if any
+func mistakeAt(mistakes []mistake, lapProgress float64) (mistake, bool) {
+	for _, m := range mistakes {
+		if math.Abs(lapProgress-m.apex) < mistakeCornerWidth {
+			return m, true
+		}
+	}
+	return mistake{}, false
+}
+
+// speedFactor returns the corner speed multiplier of a mistake: missed apexes
+// lose speed through the whole corner, lock-ups mostly on entry
+func (m mistake) speedFactor(lapProgress float64) float64 {
+	if m.kind == mistakeMissedApex {
+		return 0.9
+	}
+	if lapProgress <= m.apex {
+		return 0.85
+	}
+	return 0.95
+}
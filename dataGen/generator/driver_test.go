@@ -0,0 +1,83 @@
+package generator
+
+import (
+	"context"
+	"testing"
+)
+
+// TestCompetitorDriver checks that a competitor without a built-in profile
+// drives in the balanced style, and our car in the style of the options
+func TestCompetitorDriver(t *testing.T) {
+	opts := DefaultOptions()
+	rookie := driverProfiles["rookie"]
+	opts.Driver = &rookie
+	cases := []struct {
+		c    Competitor
+		want string
+	}{
+		{Competitor{Driver: "smooth"}, "smooth"},
+		{Competitor{}, "balanced"},
+		{Competitor{Driver: "unknown"}, "balanced"},
+		{Competitor{Driver: "smooth", OurCar: true}, "rookie"},
+	}
+	for _, c := range cases {
+		if got := competitorDriver(opts, c.c); got.Name != c.want {
+			t.Errorf("driver %q, our car %v: got %s, want %s", c.c.Driver, c.c.OurCar, got.Name, c.want)
+		}
+	}
+}
+
+// TestDriverStyle checks on the same seed that an aggressive driver brakes
+// later for the heavy braking corners than a smooth one, and gets on the
+// throttle harder out of them
+func TestDriverStyle(t *testing.T) {
+	heavyBraking := []int{0, 3, 5, 8} // Sainte Devote, Mirabeau, Loews, the chicane
+
+	style := func(name string) (brakingPoint, throttle float64) {
+		opts := DefaultOptions()
+		opts.Laps = 5
+		opts.SampleRate = 100
+		opts.EngineFailures = []EngineFailure{}
+		driver := driverProfiles[name]
+		opts.Driver = &driver
+		result, err := Generate(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, p := result.Telemetry, result.RaceParameters
+
+		// The braking point is the fastest sample in the run up to the apex,
+		// throttle the mean applied while the car gains speed
+		var corners, accelerating int
+		for lap := 1; lap <= opts.Laps; lap++ {
+			for _, k := range heavyBraking {
+				apex := monacoCorners[k].Apex
+				point, fastest := 0.0, 0.0
+				for i := 0; i < d.Len(); i++ {
+					progress := d.Distance[i]/p.TrackLength - float64(d.Lap[i]-1)
+					if d.Lap[i] == lap && progress > apex-0.05 && progress < apex && d.Speed[i] > fastest {
+						point, fastest = progress, d.Speed[i]
+					}
+				}
+				brakingPoint += apex - point
+				corners++
+			}
+		}
+		for i := 1; i < d.Len(); i++ {
+			if d.PitLimiter[i] == 0 && d.Speed[i] > d.Speed[i-1] {
+				throttle += d.Throttle[i]
+				accelerating++
+			}
+		}
+		return brakingPoint / float64(corners) * p.TrackLength * 1000, throttle / float64(accelerating)
+	}
+
+	aggressiveBraking, aggressiveThrottle := style("aggressive")
+	smoothBraking, smoothThrottle := style("smooth")
+	if aggressiveBraking >= smoothBraking {
+		t.Errorf("aggressive driver brakes %.1fm before the apex, smooth driver %.1fm", aggressiveBraking, smoothBraking)
+	}
+	if aggressiveThrottle <= smoothThrottle {
+		t.Errorf("aggressive driver averages %.1f%% throttle accelerating, smooth driver %.1f%%", aggressiveThrottle, smoothThrottle)
+	}
+}
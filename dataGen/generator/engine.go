@@ -0,0 +1,241 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Engine failure modes
+const (
+	FailureOilLeak     = "oil_leak"    // oil is lost, the pressure falls away and the oil runs hot
+	FailureOverheating = "overheating" // the cooling fails and the water and oil temperatures climb
+)
+
+// EngineFailure is a slowly developing power unit failure that ends in the
+// car retiring, recorded in the ground truth. Scenarios set Mode, Lap,
+// Progress and Laps; the rest is filled in by the generator
+type EngineFailure struct {
+	CarNumber      int     `json:"car_number"`
+	Mode           string  `json:"mode"`            // FailureOilLeak or FailureOverheating
+	Lap            int     `json:"lap"`             // lap the failure sets in
+	Progress       float64 `json:"progress"`        // lap progress the failure sets in
+	Laps           float64 `json:"laps"`            // laps from the onset to the car retiring
+	RetireLap      int     `json:"retire_lap"`      // lap the car retires on
+	RetireProgress float64 `json:"retire_progress"` // lap progress the car retires at
+	Retired        bool    `json:"retired"`         // the car retires before the end of the session
+}
+
+// Engine failure model
+const (
+	engineFailureChance = 0.0005 // chance of a failure setting in per lap
+	engineStream        = -3     // random stream engine failures are drawn from
+)
+
+// Laps an engine failure takes to develop from its onset to the car retiring
+var failureLaps = Range{1.5, 6}
+
+// Power unit thermal model. Water and oil temperatures follow the engine
+// load with a lag and are cooled by the airflow; the turbo spins up with
+// the load far faster
+const (
+	enginePower     = 600    // m²/s³ of power per unit mass at full load
+	waterTempIdle   = 95     // °C at no load and no airflow
+	waterTempLoad   = 25     // extra °C at full load
+	waterTempAir    = 15     // °C of cooling at top speed
+	waterTau        = 25     // s
+	oilTempIdle     = 105    // °C at no load and no airflow
+	oilTempLoad     = 30     // extra °C at full load
+	oilTempAir      = 12     // °C of cooling at top speed
+	oilTau          = 45     // s
+	turboIdle       = 30000  // rpm
+	turboMax        = 125000 // rpm at full load
+	turboTau        = 0.4    // s
+	oilPressureBase = 1.5    // bar at 5000 rpm with the oil at 110 °C
+	oilPressureRPM  = 0.0004 // bar per rpm above 5000
+)
+
+// Failure effects at the moment the car retires
+const (
+	leakPressureLoss  = 0.75 // fraction of the oil pressure lost to a leak
+	leakOilHeat       = 35   // °C the oil runs hotter with a leak
+	overheatWaterHeat = 40   // °C the water runs hotter when the cooling fails
+	overheatOilHeat   = 25   // °C the oil runs hotter when the cooling fails
+)
+
+// validateEngineFailures checks scenario engine failures against a session
+// of laps laps
+func validateEngineFailures(failures []EngineFailure, laps int) error {
+	if len(failures) > 1 {
+		return errors.New("generator: a car can suffer at most one engine failure")
+	}
+	for _, f := range failures {
+		switch {
+		case f.Mode != FailureOilLeak && f.Mode != FailureOverheating:
+			return fmt.Errorf("generator: unknown engine failure mode %q", f.Mode)
+		case f.Lap < 1 || f.Lap > laps:
+			return fmt.Errorf("generator: engine failure lap %d outside range [1, %d]", f.Lap, laps)
+		case f.Progress < 0 || f.Progress >= 1:
+			return fmt.Errorf("generator: engine failure progress %g outside range [0, 1)", f.Progress)
+		case f.Laps <= 0:
+			return errors.New("generator: engine failure laps must be positive")
+		}
+	}
+	return nil
+}
+
+// planEngineFailure returns the engine failure of a car over the session:
+// the first of failures when given, otherwise one drawn at random from the
+// car's own stream, or nil for a reliable engine
+func planEngineFailure(opts Options, carNumber int, failures []EngineFailure) *EngineFailure {
+	var failure EngineFailure
+	switch {
+	case failures != nil && len(failures) == 0:
+		return nil
+	case failures != nil:
+		failure = failures[0]
+	default:
+		s := newSampler(deriveSeed(opts.Seed, carNumber, engineStream))
+		for lap := 1; lap <= opts.Laps && failure.Lap == 0; lap++ {
+			if s.float64() < engineFailureChance {
+				mode := FailureOilLeak
+				if s.float64() < 0.5 {
+					mode = FailureOverheating
+				}
+				failure = EngineFailure{Mode: mode, Lap: lap, Progress: s.float64(),
+					Laps: s.uniformRandom(failureLaps.Min, failureLaps.Max)}
+			}
+		}
+		if failure.Lap == 0 {
+			return nil
+		}
+	}
+
+	failure.CarNumber = carNumber
+	failure.Progress = round3(failure.Progress)
+	failure.Laps = round3(failure.Laps)
+	retire := float64(failure.Lap) + failure.Progress + failure.Laps
+	failure.RetireLap = int(retire)
+	failure.RetireProgress = round3(retire - math.Floor(retire))
+	failure.Retired = failure.RetireLap <= opts.Laps
+	return &failure
+}
+
+// mode returns the failure mode, empty for a reliable engine
+func (f *EngineFailure) mode() string {
+	if f == nil {
+		return ""
+	}
+	return f.Mode
+}
+
+// severity returns how far the failure has developed at lapProgress on lap,
+// from 0 before the onset to 1 as the car retires
+func (f *EngineFailure) severity(lap int, lapProgress float64) float64 {
+	if f == nil {
+		return 0
+	}
+	elapsed := float64(lap-f.Lap) + lapProgress - f.Progress
+	return clamp(elapsed/f.Laps, 0, 1)
+}
+
+// retiredBy reports whether the car has retired by lapProgress on lap
+func (f *EngineFailure) retiredBy(lap int, lapProgress float64) bool {
+	if f == nil || !f.Retired {
+		return false
+	}
+	return lap > f.RetireLap || lap == f.RetireLap && lapProgress >= f.RetireProgress
+}
+
+// withinSession drops the pit stops, damage and engine failure a car would
+// have after retiring or after its last lap of the session
+func (c carSetup) withinSession() carSetup {
+	last := 0
+	if len(c.laps) > 0 {
+		last = c.laps[len(c.laps)-1].lap
+	}
+	if c.failure != nil && c.failure.Lap > last {
+		c.failure = nil
+	}
+	if f := c.failure; f != nil && f.Retired && f.RetireLap > last {
+		f.Retired = false
+	}
+	var stops []PitStop
+	for _, stop := range c.stops {
+		if stop.Lap <= last && (c.failure == nil || !c.failure.Retired || stop.Lap < c.failure.RetireLap) {
+			stops = append(stops, stop)
+		}
+	}
+	var damage []DamageEvent
+	for _, e := range c.damage {
+		if e.Lap <= last && !c.failure.retiredBy(e.Lap, e.Progress) {
+			damage = append(damage, e)
+		}
+	}
+	c.stops, c.damage = stops, damage
+	return c
+}
+
+// engineState is the thermal state of the power unit
+type engineState struct {
+	waterTemp  float64 // °C
+	oilTemp    float64 // °C
+	turboSpeed float64 // rpm
+}
+
+// engineStartState is the power unit warmed up on the grid
+var engineStartState = engineState{waterTemp: waterTempIdle, oilTemp: oilTempIdle, turboSpeed: turboIdle}
+
+// engineLoad returns the fraction of full power needed to accelerate from
+// prev to speed km/h over dt seconds against drag and rolling resistance
+func engineLoad(prev, speed, dt, maxSpeed float64) float64 {
+	const kmh = 3.6 // km/h per m/s
+	accel := (speed-prev)/kmh/dt + coastDeceleration(speed, maxSpeed)
+	return clamp(accel*speed/kmh/enginePower, 0, 1)
+}
+
+// step advances the power unit by dt seconds at speed km/h and load, with
+// the failure mode developed to severity
+func (e *engineState) step(speed, load, dt, maxSpeed float64, mode string, severity float64) {
+	airflow := speed / maxSpeed
+	water := waterTempIdle + waterTempLoad*load - waterTempAir*airflow
+	oil := oilTempIdle + oilTempLoad*load - oilTempAir*airflow
+	switch mode {
+	case FailureOilLeak:
+		oil += leakOilHeat * severity
+	case FailureOverheating:
+		water += overheatWaterHeat * severity
+		oil += overheatOilHeat * severity
+	}
+	turbo := turboIdle + (turboMax-turboIdle)*load
+
+	e.waterTemp += (water - e.waterTemp) * math.Min(dt/waterTau, 1)
+	e.oilTemp += (oil - e.oilTemp) * math.Min(dt/oilTau, 1)
+	e.turboSpeed += (turbo - e.turboSpeed) * math.Min(dt/turboTau, 1)
+}
+
+// oilPressure returns the oil pressure in bar at rpm: it rises with engine
+// speed, falls as the oil thins when hot and falls away with a leak
+func (e engineState) oilPressure(rpm float64, mode string, severity float64) float64 {
+	pressure := oilPressureBase + oilPressureRPM*math.Max(rpm-5000, 0)
+	pressure *= clamp(1-0.01*(e.oilTemp-110), 0.5, 1.3)
+	if mode == FailureOilLeak {
+		pressure *= 1 - leakPressureLoss*severity
+	}
+	return pressure
+}
+
+// engineStates returns the power unit state after every sample of plan,
+// starting from start
+func (m *telemetryModel) engineStates(start engineState, plan *lapPlan) []engineState {
+	f, dt := m.car.failure, 1/m.sampleRate
+	out := make([]engineState, len(plan.speeds))
+	prev := plan.entrySpeed
+	for i, speed := range plan.speeds {
+		load := engineLoad(prev, speed, dt, m.params.MaxSpeed)
+		start.step(speed, load, dt, m.params.MaxSpeed, f.mode(), f.severity(plan.lap, plan.progressAt(i, m.samplesPerLap)))
+		out[i] = start
+		prev = speed
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package generator
+
+import (
+	"context"
+	"testing"
+)
+
+// TestEngineFailureRetires checks that a scenario oil leak shows as falling
+// oil pressure and rising oil temperature, that the car rolls to a stop
+// where the ground truth says it retires, and that the timing feed drops it
+func TestEngineFailureRetires(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Laps = 4
+	opts.EngineFailures = []EngineFailure{}
+	reliable, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.EngineFailures = []EngineFailure{{Mode: FailureOilLeak, Lap: 1, Progress: 0.3, Laps: 1.2}}
+	failing, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var failures []EngineFailure
+	for _, f := range failing.GroundTruth.EngineFailures {
+		if f.CarNumber == opts.carNumber() {
+			failures = append(failures, f)
+		}
+	}
+	if len(failures) != 1 || !failures[0].Retired || failures[0].RetireLap != 2 || failures[0].RetireProgress != 0.5 {
+		t.Fatalf("ground truth engine failures %+v, want a retirement at 0.5 on lap 2", failures)
+	}
+
+	d, p := failing.Telemetry, failing.RaceParameters
+	last := d.Len() - 1
+	if d.Lap[last] != 2 || d.Speed[last] != 0 {
+		t.Fatalf("telemetry ends on lap %d at %v km/h, want stopped on lap 2", d.Lap[last], d.Speed[last])
+	}
+	if progress := d.Distance[last]/p.TrackLength - 1; progress < 0.5 || progress > 0.6 {
+		t.Errorf("car stops at lap progress %.3f, want just past 0.5", progress)
+	}
+
+	// Compare the half lap before the car retires against a reliable engine
+	var reliablePressure, failingPressure, reliableOil, failingOil float64
+	for i := 0; i < d.Len(); i++ {
+		progress := d.Distance[i]/p.TrackLength - float64(d.Lap[i]-1)
+		if d.Lap[i] != 2 || progress >= 0.5 {
+			continue
+		}
+		reliablePressure += reliable.Telemetry.OilPressure[i]
+		failingPressure += d.OilPressure[i]
+		reliableOil += reliable.Telemetry.OilTemp[i]
+		failingOil += d.OilTemp[i]
+	}
+	if failingPressure > 0.7*reliablePressure || failingOil <= reliableOil {
+		t.Errorf("oil leak left pressure %.0f/%.0f and temperature %.0f/%.0f", failingPressure, reliablePressure, failingOil, reliableOil)
+	}
+
+	retirements := 0
+	for _, msg := range failing.TimingFeed {
+		switch data := msg.Data.(type) {
+		case RetirementData:
+			if data.CarNumber == opts.carNumber() {
+				retirements++
+			}
+		case LapCompletedData:
+			if data.CarNumber == opts.carNumber() && data.Lap >= 2 {
+				t.Errorf("car completes lap %d after retiring", data.Lap)
+			}
+		}
+	}
+	if retirements != 1 {
+		t.Errorf("%d retirement messages for our car, want 1", retirements)
+	}
+}
+
+// TestRetiringDeploysNoBattery checks that a car coasting to a stop in the
+// tunnel after its engine fails deploys no battery power
+func TestRetiringDeploysNoBattery(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Laps = 3
+	opts.EngineFailures = []EngineFailure{{Mode: FailureOverheating, Lap: 1, Progress: 0.4, Laps: 1.2}}
+	result, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, p := result.Telemetry, result.RaceParameters
+	coasting := 0
+	for i := 0; i < d.Len(); i++ {
+		progress := d.Distance[i]/p.TrackLength - float64(d.Lap[i]-1)
+		if d.Lap[i] != 2 || progress < 0.6 {
+			continue
+		}
+		coasting++
+		if d.BatteryDeployment[i] != 0 {
+			t.Fatalf("%v kW deployed at lap progress %.3f after retiring", d.BatteryDeployment[i], progress)
+		}
+	}
+	if coasting == 0 {
+		t.Error("no samples after the car retires in the tunnel")
+	}
+}
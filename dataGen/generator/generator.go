@@ -0,0 +1,351 @@
+// Package generator produces synthetic Monaco GP telemetry, race parameters,
+// competitor data and a live timing feed. Every run is driven by a seeded
+// random source, so the same Options always produce the same data.
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Options controls a generation run
+type Options struct {
+	Seed int64 // random seed, runs with the same seed are identical
+	Laps int   // number of laps of telemetry and timing to generate
+
+	// Session is the session type, one of SessionTypes. Empty uses
+	// SessionRun. Practice and qualifying cars may run fewer than Laps laps.
+	Session string
+
+	// Parameters drives the telemetry, competitor and timing models.
+	// Nil uses MonacoParameters.
+	Parameters *RaceParameters
+
+	// Calibration holds the telemetry model constants. Nil uses
+	// DefaultCalibration.
+	Calibration *Calibration
+
+	// Driver is the driving style of our car. Nil uses DefaultDriver.
+	Driver *Driver
+
+	// GridSize is the number of cars in the race, ours included. Zero uses
+	// DefaultGridSize.
+	GridSize int
+
+	// CarNumber is our car's number. Zero uses DefaultCarNumber.
+	CarNumber int
+
+	// GridPosition is our car's position in the standings, from 1 to
+	// GridSize. Zero uses DefaultGridPosition, or the back of a smaller grid.
+	GridPosition int
+
+	// PitLane describes the track's pit lane. Nil uses the pit lane of the
+	// track named in Parameters, or Monaco's for unknown tracks.
+	PitLane *PitLane
+
+	// PitLaps are the laps at the end of which our car stops, before the
+	// last lap. Nil stops when the tires pass 28 laps.
+	PitLaps []int
+
+	// PitCompounds are the compounds fitted at each of PitLaps in order.
+	// Stops without one fit a different compound drawn at random.
+	PitCompounds []string
+
+	// Compounds describes the tire compounds by name. Nil uses the
+	// compounds of the track named in Parameters, or Monaco's for unknown
+	// tracks.
+	Compounds map[string]Compound
+
+	// DamageEvents are the aero damage events of our car. Nil draws them
+	// at random; an empty list means no damage.
+	DamageEvents []DamageEvent
+
+	// EngineFailures holds the engine failure of our car, at most one. Nil
+	// draws one at random, rarely; an empty list means a reliable engine.
+	EngineFailures []EngineFailure
+
+	// Overtaking weights the overtaking probability of cars held up in the
+	// timing model. Nil uses DefaultOvertakeModel.
+	Overtaking *OvertakeModel
+
+	// SampleRate is the number of telemetry samples per second, up to
+	// MaxSampleRate. Zero uses DefaultSampleRate.
+	SampleRate float64
+
+	// Workers is the number of goroutines generating telemetry. Zero uses
+	// one per CPU.
+	Workers int
+
+	// Progress, if set, is called after each lap of telemetry is passed on
+	// with the number of laps done and the total across all cars. It is
+	// called from the goroutine that started generation.
+	Progress func(done, total int)
+}
+
+// Grid defaults and limits
+const (
+	DefaultGridSize     = 20
+	DefaultCarNumber    = 10
+	DefaultGridPosition = 10
+	MaxGridSize         = 40
+	MaxCarNumber        = 99
+)
+
+// Telemetry sample rate limits in samples per second
+const (
+	DefaultSampleRate = 10.0
+	MaxSampleRate     = 10000.0
+)
+
+// Result holds everything produced by a generation run
+type Result struct {
+	Telemetry      *TelemetryData
+	RaceParameters RaceParameters
+	Competitors    []Competitor
+	TimingFeed     []TimingMessage
+	GroundTruth    GroundTruth
+	Laps           []LapSummary // our car's completed laps, set by Generate
+
+	plans *sessionPlans // every car's laps over the session
+}
+
+// DefaultOptions returns the options used by the command line generator
+func DefaultOptions() Options {
+	return Options{
+		Seed: 42,
+		Laps: 10,
+	}
+}
+
+// validate checks that the options describe a session that can be generated
+func (o Options) validate() error {
+	if o.Laps < 1 {
+		return errors.New("generator: laps must be at least 1")
+	}
+	if o.SampleRate < 0 || o.SampleRate > MaxSampleRate {
+		return fmt.Errorf("generator: sample rate %g outside range [0, %g]", o.SampleRate, MaxSampleRate)
+	}
+	if o.GridSize < 0 || o.GridSize == 1 || o.GridSize > MaxGridSize {
+		return fmt.Errorf("generator: grid size %d outside range [2, %d]", o.GridSize, MaxGridSize)
+	}
+	if o.CarNumber < 0 || o.CarNumber > MaxCarNumber {
+		return fmt.Errorf("generator: car number %d outside range [1, %d]", o.CarNumber, MaxCarNumber)
+	}
+	if o.GridPosition < 0 || o.GridPosition > o.gridSize() {
+		return fmt.Errorf("generator: grid position %d outside range [1, %d]", o.GridPosition, o.gridSize())
+	}
+	if err := o.validateSession(); err != nil {
+		return err
+	}
+	if o.Workers < 0 {
+		return errors.New("generator: workers must not be negative")
+	}
+	for _, lap := range o.PitLaps {
+		if lap < 1 || lap >= o.Laps {
+			return fmt.Errorf("generator: pit lap %d outside range [1, %d]", lap, o.Laps-1)
+		}
+	}
+	if err := validateDamageEvents(o.DamageEvents, o.Laps); err != nil {
+		return err
+	}
+	if err := validateEngineFailures(o.EngineFailures, o.Laps); err != nil {
+		return err
+	}
+	if err := o.pitLane().Validate(); err != nil {
+		return err
+	}
+	compounds := o.compounds()
+	if err := validateCompounds(compounds); err != nil {
+		return err
+	}
+	allowed := compoundNames(compounds)
+	for _, name := range append([]string{o.parameters().TireCompound}, o.PitCompounds...) {
+		if !containsString(allowed, name) {
+			return fmt.Errorf("generator: tire compound %q is not one of %s", name, strings.Join(allowed, ", "))
+		}
+	}
+	if err := o.driver().Validate(); err != nil {
+		return err
+	}
+	if err := o.calibration().Validate(); err != nil {
+		return err
+	}
+	if err := o.overtaking().Validate(); err != nil {
+		return err
+	}
+	return o.parameters().Validate()
+}
+
+// driver returns the driver profile of our car
+func (o Options) driver() Driver {
+	if o.Driver != nil {
+		return *o.Driver
+	}
+	return DefaultDriver()
+}
+
+// gridSize returns the number of cars in the race
+func (o Options) gridSize() int {
+	if o.GridSize > 0 {
+		return o.GridSize
+	}
+	return DefaultGridSize
+}
+
+// carNumber returns our car's number
+func (o Options) carNumber() int {
+	if o.CarNumber > 0 {
+		return o.CarNumber
+	}
+	return DefaultCarNumber
+}
+
+// gridPosition returns our car's position in the standings
+func (o Options) gridPosition() int {
+	if o.GridPosition > 0 {
+		return o.GridPosition
+	}
+	if o.gridSize() < DefaultGridPosition {
+		return o.gridSize()
+	}
+	return DefaultGridPosition
+}
+
+// pitLane returns the pit lane of the track
+func (o Options) pitLane() PitLane {
+	if o.PitLane != nil {
+		return *o.PitLane
+	}
+	if lane, ok := pitLanes[o.parameters().TrackName]; ok {
+		return lane
+	}
+	return pitLanes["Monaco"]
+}
+
+// compounds returns the tire compounds of the track
+func (o Options) compounds() map[string]Compound {
+	if o.Compounds != nil {
+		return o.Compounds
+	}
+	if compounds, ok := trackCompounds[o.parameters().TrackName]; ok {
+		return compounds
+	}
+	return trackCompounds["Monaco"]
+}
+
+// sampleRate returns the telemetry samples per second
+func (o Options) sampleRate() float64 {
+	if o.SampleRate > 0 {
+		return o.SampleRate
+	}
+	return DefaultSampleRate
+}
+
+// workers returns the number of telemetry goroutines
+func (o Options) workers() int {
+	if o.Workers > 0 {
+		return o.Workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// calibration returns a private copy of the telemetry model constants,
+// ready for use
+func (o Options) calibration() *Calibration {
+	cal := DefaultCalibration()
+	if o.Calibration != nil {
+		cal = *o.Calibration
+	}
+	cal.buildKnots()
+	return &cal
+}
+
+// parameters returns the race parameters to generate with
+func (o Options) parameters() *RaceParameters {
+	if o.Parameters != nil {
+		return o.Parameters
+	}
+	params := MonacoParameters()
+	return &params
+}
+
+// Generate runs a full generation and returns all outputs in memory
+func Generate(ctx context.Context, opts Options) (*Result, error) {
+	result, err := GenerateSession(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Telemetry = newTelemetryData(0)
+	err = StreamSessionTelemetry(ctx, opts, result, func(lap *TelemetryData) error {
+		result.Telemetry.Append(lap)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Laps = SummarizeLaps(opts, result, result.Telemetry)
+	return result, nil
+}
+
+// GenerateSession generates everything except telemetry: the race
+// parameters, competitors and timing feed. Combined with
+// StreamSessionTelemetry it produces the same data as Generate without
+// holding the telemetry in memory.
+// The timing model stops when ctx is cancelled.
+func GenerateSession(ctx context.Context, opts Options) (*Result, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	return simulateSession(ctx, opts)
+}
+
+// simulateSession runs the competitor and timing models of opts, which
+// must be valid
+func simulateSession(ctx context.Context, opts Options) (*Result, error) {
+	s := newSampler(opts.Seed)
+	params := opts.parameters()
+	competitors := generateCompetitorData(s, params, opts)
+	plans := planSession(opts, competitors)
+	gt := sessionGroundTruth(opts, competitors, plans)
+	feed, err := generateTimingFeed(ctx, s, opts, competitors, plans, &gt)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		RaceParameters: *params,
+		Competitors:    competitors,
+		TimingFeed:     feed,
+		GroundTruth:    gt,
+		plans:          plans,
+	}, nil
+}
+
+// StreamTelemetry generates telemetry lap by lap on opts.Workers goroutines,
+// passing each lap to fn in order as soon as it and every lap before it are
+// complete. Generation stops at the first error returned by fn or when ctx
+// is cancelled. It runs the timing model for the traffic our car meets; a
+// caller with the result of GenerateSession can use StreamSessionTelemetry
+// instead.
+func StreamTelemetry(ctx context.Context, opts Options, fn func(lap *TelemetryData) error) error {
+	session, err := GenerateSession(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return StreamSessionTelemetry(ctx, opts, session, fn)
+}
+
+// StreamSessionTelemetry is StreamTelemetry for the session GenerateSession
+// returned for opts, taking the traffic our car meets from its ground truth.
+func StreamSessionTelemetry(ctx context.Context, opts Options, session *Result, fn func(lap *TelemetryData) error) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	return runTelemetryPipeline(ctx, opts, []carSetup{ourCar(opts, session.plans)}, session.GroundTruth.Traffic, func(_ carSetup, lap *TelemetryData) error {
+		return fn(lap)
+	})
+}
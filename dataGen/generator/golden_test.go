@@ -0,0 +1,147 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenOptions pins the run the golden files were generated from. Our car
+// stops after lap 1 so the pit lane is pinned too
+func goldenOptions() Options {
+	opts := DefaultOptions()
+	opts.Laps = 2
+	opts.PitLaps = []int{1}
+	return opts
+}
+
+// TestGolden regenerates every output file from a fixed seed and compares it
+// with testdata. Run go test -update after an intended change to the model.
+func TestGolden(t *testing.T) {
+	result, err := Generate(context.Background(), goldenOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"telemetry_data.csv", func(w io.Writer) error { return WriteTelemetryCSV(w, result.Telemetry) }},
+		{"race_parameters.csv", func(w io.Writer) error { return WriteRaceParametersCSV(w, result.RaceParameters) }},
+		{"competitor_data.csv", func(w io.Writer) error { return WriteCompetitorCSV(w, result.Competitors) }},
+		{"timing_feed.jsonl", func(w io.Writer) error { return WriteTimingFeed(w, result.TimingFeed) }},
+		{"ground_truth.json", func(w io.Writer) error { return WriteGroundTruth(w, result.GroundTruth) }},
+		{"laps.csv", func(w io.Writer) error { return WriteLapSummaryCSV(w, result.Laps) }},
+	}
+	for _, f := range files {
+		t.Run(f.name, func(t *testing.T) {
+			var got bytes.Buffer
+			if err := f.write(&got); err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", f.name)
+			if *update {
+				if err := os.WriteFile(golden, got.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("%s differs from %s at line %d; run go test -update if the change is intended",
+					f.name, golden, firstDifferentLine(got.Bytes(), want))
+			}
+		})
+	}
+}
+
+// TestGoldenWorkers checks that the worker count never changes the output
+func TestGoldenWorkers(t *testing.T) {
+	var outputs [][]byte
+	for _, workers := range []int{1, 4} {
+		opts := goldenOptions()
+		opts.Workers = workers
+		result, err := Generate(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := WriteTelemetryCSV(&buf, result.Telemetry); err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, buf.Bytes())
+	}
+	if !bytes.Equal(outputs[0], outputs[1]) {
+		t.Errorf("telemetry differs between 1 and 4 workers at line %d", firstDifferentLine(outputs[0], outputs[1]))
+	}
+}
+
+// TestGoldenGrid checks that grid telemetry arrives in order of lap and then
+// car number, and that our car's laps in it match its single-car telemetry
+// whatever its number
+func TestGoldenGrid(t *testing.T) {
+	for _, number := range []int{1, 16, MaxCarNumber} {
+		opts := goldenOptions()
+		opts.CarNumber = number
+		single, err := Generate(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		session, err := GenerateSession(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ours TelemetryData
+		last := [2]int{-1, 0} // lap and car number of the previous lap
+		err = StreamGridTelemetry(context.Background(), opts, session, func(carNumber int, lap *TelemetryData) error {
+			if next := [2]int{lap.Lap[0], carNumber}; next[0] < last[0] || next[0] == last[0] && next[1] <= last[1] {
+				t.Errorf("car %d: lap %d of car %d follows lap %d of car %d", number, next[0], next[1], last[0], last[1])
+			} else {
+				last = next
+			}
+			if carNumber == number {
+				ours.Append(lap)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var want, got bytes.Buffer
+		if err := WriteTelemetryCSV(&want, single.Telemetry); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteTelemetryCSV(&got, &ours); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Errorf("car %d: grid telemetry differs from single-car telemetry at line %d", number, firstDifferentLine(got.Bytes(), want.Bytes()))
+		}
+	}
+}
+
+// firstDifferentLine returns the 1-based line where a and b first differ
+func firstDifferentLine(a, b []byte) int {
+	line := 1
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return line
+		}
+		if a[i] == '\n' {
+			line++
+		}
+	}
+	return line
+}
@@ -0,0 +1,31 @@
+package generator
+
+import (
+	"context"
+	"sort"
+)
+
+// StreamGridTelemetry generates telemetry for our car and every competitor
+// of the session GenerateSession returned for opts concurrently. Laps are
+// passed to fn in order of lap and then car number, so the output is the
+// same however the work is scheduled, and our car's laps match those
+// StreamSessionTelemetry produces. Generation stops at the first error
+// returned by fn or when ctx is cancelled.
+func StreamGridTelemetry(ctx context.Context, opts Options, session *Result, fn func(carNumber int, lap *TelemetryData) error) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	cars := []carSetup{ourCar(opts, session.plans)}
+	for _, c := range session.Competitors {
+		if c.OurCar {
+			continue
+		}
+		cars = append(cars, competitorCar(opts, c, session.plans))
+	}
+	sort.Slice(cars, func(i, j int) bool { return cars[i].number < cars[j].number })
+
+	return runTelemetryPipeline(ctx, opts, cars, session.GroundTruth.Traffic, func(car carSetup, lap *TelemetryData) error {
+		return fn(car.number, lap)
+	})
+}
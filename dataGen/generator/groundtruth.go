@@ -0,0 +1,48 @@
+package generator
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// GroundTruth records the events behind the generated data, so analysis
+// tools can be checked against what actually happened
+type GroundTruth struct {
+	PitStops []PitStop     `json:"pit_stops"`
+	Damage   []DamageEvent `json:"damage"`
+
+	EngineFailures []EngineFailure `json:"engine_failures"`
+	Starts         []RaceStart     `json:"starts"`
+	Traffic        []TrafficDelay  `json:"traffic"`   // set once the timing feed is generated
+	Overtakes      []Overtake      `json:"overtakes"` // set once the timing feed is generated
+}
+
+// sessionGroundTruth plans the events of every car in the standings, in
+// standings order
+func sessionGroundTruth(opts Options, competitors []Competitor, plans *sessionPlans) GroundTruth {
+	var gt GroundTruth
+	for _, c := range competitors {
+		var car carSetup
+		if c.OurCar {
+			car = ourCar(opts, plans)
+		} else {
+			car = competitorCar(opts, c, plans)
+		}
+		gt.PitStops = append(gt.PitStops, car.stops...)
+		gt.Damage = append(gt.Damage, car.damage...)
+		if car.failure != nil {
+			gt.EngineFailures = append(gt.EngineFailures, *car.failure)
+		}
+		if car.start != nil {
+			gt.Starts = append(gt.Starts, *car.start)
+		}
+	}
+	return gt
+}
+
+// WriteGroundTruth writes gt to w as indented JSON
+func WriteGroundTruth(w io.Writer, gt GroundTruth) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(gt)
+}
@@ -0,0 +1,149 @@
+package generator
+
+import "math"
+
+// sectorEnds is the lap progress at which each timing sector ends
+var sectorEnds = [3]float64{0.32, 0.68, 1}
+
+// LapSummary holds the statistics of one completed lap of our car, derived
+// from its telemetry
+type LapSummary struct {
+	Lap            int
+	LapType        string     // LapRacing and so on
+	LapTime        float64    // seconds
+	SectorTimes    [3]float64 // seconds
+	TopSpeed       float64    // km/h
+	MinCornerSpeed float64    // km/h, lowest on track outside the pit lane and the grid
+	TireTempFL     float64    // °C averaged over the lap
+	TireTempFR     float64
+	TireTempRL     float64
+	TireTempRR     float64
+	FuelUsed       float64 // kg
+	ERSDeployed    float64 // MJ
+	TireCompound   string
+	TireAge        int // laps on the tires at the start of the lap
+}
+
+// LapSummarizer builds lap summaries of our car from its telemetry as it is
+// streamed
+type LapSummarizer struct {
+	car         carSetup
+	trackLength float64
+	compound    string
+	tireAge     int
+	laps        []LapSummary
+}
+
+// NewLapSummarizer creates a LapSummarizer for the telemetry of our car
+// generated with opts in session, as returned by GenerateSession
+func NewLapSummarizer(opts Options, session *Result) *LapSummarizer {
+	car := ourCar(opts, session.plans)
+	return &LapSummarizer{
+		car:         car,
+		trackLength: opts.parameters().TrackLength,
+		compound:    car.compound,
+		tireAge:     car.tireAge,
+	}
+}
+
+// Add summarizes the laps in data, which must hold whole laps following
+// those already added
+func (l *LapSummarizer) Add(data *TelemetryData) {
+	for start := 0; start < data.Len(); {
+		end := start
+		for end < data.Len() && data.Lap[end] == data.Lap[start] {
+			end++
+		}
+		l.addLap(data, start, end)
+		start = end
+	}
+}
+
+// addLap summarizes the lap held in samples start to end of data. The lap
+// the car retires on is never completed, so it has no summary
+func (l *LapSummarizer) addLap(data *TelemetryData, start, end int) {
+	lap := data.Lap[start]
+	kind := LapRacing
+	var standing, toGrid bool
+	for i, sl := range l.car.laps {
+		if sl.lap != lap {
+			continue
+		}
+		kind, standing = sl.kind, sl.standing
+		toGrid = i+1 < len(l.car.laps) && l.car.laps[i+1].standing
+		if sl.newRun {
+			l.compound, l.tireAge = sl.compound, 0
+		}
+	}
+	compound, tireAge := l.compound, l.tireAge
+	l.tireAge++
+	if stop, ok := pitStopAt(l.car.stops, lap); ok {
+		l.compound, l.tireAge = stop.Compound, 0
+	}
+	if f := l.car.failure; f != nil && f.Retired && f.RetireLap == lap {
+		return
+	}
+
+	dt := 1 / data.SampleRate
+	lapStart := data.Time[start]
+	summary := LapSummary{
+		Lap:            lap,
+		LapType:        kind,
+		LapTime:        round3(data.Time[end-1] - lapStart + dt),
+		MinCornerSpeed: math.Inf(1),
+		TireCompound:   compound,
+		TireAge:        tireAge,
+	}
+
+	// Corner speeds leave out the launch from the grid and forming up on it,
+	// where the car picks up speed from rest or slows to a stop
+	cornerFrom, cornerTo := start, end
+	if standing {
+		for cornerFrom+1 < end && data.Speed[cornerFrom+1] >= data.Speed[cornerFrom] {
+			cornerFrom++
+		}
+	}
+	if toGrid {
+		for cornerTo-1 > cornerFrom && data.Speed[cornerTo-2] >= data.Speed[cornerTo-1] {
+			cornerTo--
+		}
+	}
+
+	sector := 0
+	var sectorStart float64
+	n := float64(end - start)
+	for i := start; i < end; i++ {
+		progress := data.Distance[i]/l.trackLength - float64(lap-1)
+		for sector < len(sectorEnds)-1 && progress >= sectorEnds[sector] {
+			summary.SectorTimes[sector] = round3(data.Time[i] - lapStart - sectorStart)
+			sectorStart = data.Time[i] - lapStart
+			sector++
+		}
+
+		summary.TopSpeed = math.Max(summary.TopSpeed, data.Speed[i])
+		if data.PitLimiter[i] == 0 && i >= cornerFrom && i < cornerTo {
+			summary.MinCornerSpeed = math.Min(summary.MinCornerSpeed, data.Speed[i])
+		}
+		summary.TireTempFL += data.TireTempFL[i] / n
+		summary.TireTempFR += data.TireTempFR[i] / n
+		summary.TireTempRL += data.TireTempRL[i] / n
+		summary.TireTempRR += data.TireTempRR[i] / n
+		summary.FuelUsed += data.FuelFlow[i] * dt / 3600             // kg/h
+		summary.ERSDeployed += data.BatteryDeployment[i] * dt / 1000 // kW
+	}
+	summary.SectorTimes[sector] = round3(summary.LapTime - sectorStart)
+	l.laps = append(l.laps, summary)
+}
+
+// Laps returns the summaries of the completed laps added so far
+func (l *LapSummarizer) Laps() []LapSummary {
+	return l.laps
+}
+
+// SummarizeLaps returns the lap summaries of our car's telemetry data
+// generated with opts in session
+func SummarizeLaps(opts Options, session *Result, data *TelemetryData) []LapSummary {
+	l := NewLapSummarizer(opts, session)
+	l.Add(data)
+	return l.Laps()
+}
@@ -0,0 +1,91 @@
+package generator
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// TestLapSummaries checks that the lap summaries of streamed telemetry match
+// those of the whole trace, that the sectors add up to the lap time and that
+// the compound changes after the pit stop
+func TestLapSummaries(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Laps = 3
+	opts.PitLaps = []int{1}
+	opts.PitCompounds = []string{"Soft"}
+	result, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streamed := NewLapSummarizer(opts, result)
+	err = StreamTelemetry(context.Background(), opts, func(lap *TelemetryData) error {
+		streamed.Add(lap)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(streamed.Laps(), result.Laps) {
+		t.Fatalf("streamed summaries %+v differ from %+v", streamed.Laps(), result.Laps)
+	}
+
+	if len(result.Laps) != opts.Laps {
+		t.Fatalf("%d lap summaries, want %d", len(result.Laps), opts.Laps)
+	}
+	samples := 0
+	for i, lap := range result.Laps {
+		var sectors float64
+		for _, sector := range lap.SectorTimes {
+			sectors += sector
+		}
+		if math.Abs(sectors-lap.LapTime) > 0.002 {
+			t.Errorf("lap %d sectors add up to %.3f, lap time %.3f", lap.Lap, sectors, lap.LapTime)
+		}
+		samples += int(math.Round(lap.LapTime * opts.sampleRate()))
+		if lap.MinCornerSpeed >= lap.TopSpeed || lap.FuelUsed <= 0 || lap.ERSDeployed <= 0 {
+			t.Errorf("lap %d summary %+v", lap.Lap, lap)
+		}
+		wantCompound, wantAge := result.RaceParameters.TireCompound, i
+		if lap.Lap > 1 {
+			wantCompound, wantAge = "Soft", i-1
+		}
+		if lap.TireCompound != wantCompound || lap.TireAge != wantAge {
+			t.Errorf("lap %d on %s aged %d, want %s aged %d", lap.Lap, lap.TireCompound, lap.TireAge, wantCompound, wantAge)
+		}
+	}
+	if samples != result.Telemetry.Len() {
+		t.Errorf("lap times cover %d samples, telemetry has %d", samples, result.Telemetry.Len())
+	}
+}
+
+// TestRaceLapSummaries checks that the corner speeds of the formation lap
+// and lap 1 leave out the car standing on the grid, and that lap times
+// follow the timing feed
+func TestRaceLapSummaries(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Laps = 3
+	opts.Session = SessionRace
+	opts.EngineFailures = []EngineFailure{}
+	result, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feed := make(map[int]float64) // lap -> lap time
+	for _, msg := range result.TimingFeed {
+		if data, ok := msg.Data.(LapCompletedData); ok && data.CarNumber == opts.carNumber() {
+			feed[data.Lap] = data.LapTime
+		}
+	}
+	for _, lap := range result.Laps {
+		if lap.MinCornerSpeed <= 0 {
+			t.Errorf("%s lap %d has a minimum corner speed of %.1f km/h", lap.LapType, lap.Lap, lap.MinCornerSpeed)
+		}
+		if lap.Lap > 1 && math.Abs(lap.LapTime-feed[lap.Lap]) > 1/opts.sampleRate()+1e-9 {
+			t.Errorf("lap %d summarized at %.3fs, timed at %.3fs", lap.Lap, lap.LapTime, feed[lap.Lap])
+		}
+	}
+}
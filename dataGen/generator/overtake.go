@@ -0,0 +1,98 @@
+package generator
+
+import (
+	"errors"
+	"math"
+)
+
+// OvertakeModel weights the terms of the overtaking probability
+//
+//	sigmoid(SpeedWeight*speed_delta + SlipstreamWeight*slipstream_benefit - DifficultyWeight*track_difficulty)
+//
+// where speed_delta is the attacker's average speed over the lap less the
+// defender's in km/h, and slipstream_benefit is the race parameters'
+// SlipstreamFactor when the attacker is within SlipstreamRange, as in the
+// README. Weights of 1 give the unweighted formula
+type OvertakeModel struct {
+	SpeedWeight      float64 `json:"speed_weight"` // per km/h
+	SlipstreamWeight float64 `json:"slipstream_weight"`
+	DifficultyWeight float64 `json:"difficulty_weight"`
+}
+
+// DefaultOvertakeModel returns the README's default weights, which make
+// passing rare at Monaco unless the attacker is much the faster, a few
+// percent of attempts for a second a lap
+func DefaultOvertakeModel() OvertakeModel {
+	return OvertakeModel{SpeedWeight: 0.25, SlipstreamWeight: 1, DifficultyWeight: 4}
+}
+
+// Validate checks that every weight is a finite number, not negative
+func (m OvertakeModel) Validate() error {
+	for _, w := range []float64{m.SpeedWeight, m.SlipstreamWeight, m.DifficultyWeight} {
+		if math.IsNaN(w) || math.IsInf(w, 0) {
+			return errors.New("overtake model: weights must be finite")
+		}
+	}
+	if m.SpeedWeight < 0 || m.SlipstreamWeight < 0 || m.DifficultyWeight < 0 {
+		return errors.New("overtake model: weights must not be negative")
+	}
+	return nil
+}
+
+// Overtake is one attempt by a car held up behind another to pass it,
+// recorded in the ground truth
+type Overtake struct {
+	CarNumber    int     `json:"car_number"`
+	DefendingCar int     `json:"defending_car"`
+	Lap          int     `json:"lap"`
+	SessionTime  float64 `json:"session_time"` // when the attempt is made
+	SpeedDelta   float64 `json:"speed_delta"`  // km/h the attacker is faster over the lap
+	Slipstream   bool    `json:"slipstream"`   // the attacker was within the slipstream range
+	Probability  float64 `json:"probability"`
+	Success      bool    `json:"success"`
+}
+
+// OvertakeData is the payload of an overtake message, sent for every
+// attempt whether it succeeds or not
+type OvertakeData struct {
+	CarNumber    int  `json:"car_number"`
+	DefendingCar int  `json:"defending_car"`
+	Lap          int  `json:"lap"`
+	Success      bool `json:"success"`
+}
+
+// overtakeStream identifies the random stream overtakes are drawn from
+const overtakeStream = -7
+
+// overtakeZone is the lap progress attempts are made at, the braking zone
+// of the chicane after the tunnel
+var overtakeZone = monacoCorners[8].Apex
+
+// overtaking returns the overtake model to resolve attempts with
+func (o Options) overtaking() OvertakeModel {
+	if o.Overtaking != nil {
+		return *o.Overtaking
+	}
+	return DefaultOvertakeModel()
+}
+
+// probability returns the chance an attacker speedDelta km/h faster over
+// the lap, and in the slipstream if slipstream is set, passes on a track of
+// the race parameters
+func (m OvertakeModel) probability(p *RaceParameters, speedDelta float64, slipstream bool) float64 {
+	benefit := 0.0
+	if slipstream {
+		benefit = p.SlipstreamFactor
+	}
+	return sigmoid(m.SpeedWeight*speedDelta + m.SlipstreamWeight*benefit - m.DifficultyWeight*p.TrackDifficulty)
+}
+
+// sigmoid returns the logistic function of x
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+// lapSpeed returns the average speed in km/h of a lap of lapTime seconds
+func lapSpeed(p *RaceParameters, lapTime float64) float64 {
+	return p.TrackLength * 3600 / lapTime
+}
@@ -0,0 +1,94 @@
+package generator
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// TestOvertakeProbability checks that unit weights give the README formula
+// and that a faster car or a slipstream makes a pass more likely
+func TestOvertakeProbability(t *testing.T) {
+	p := MonacoParameters()
+	readme := OvertakeModel{SpeedWeight: 1, SlipstreamWeight: 1, DifficultyWeight: 1}
+	want := 1 / (1 + math.Exp(-(2 + p.SlipstreamFactor - p.TrackDifficulty)))
+	if got := readme.probability(&p, 2, true); math.Abs(got-want) > 1e-12 {
+		t.Errorf("README probability %v, want %v", got, want)
+	}
+
+	m := DefaultOvertakeModel()
+	if m.probability(&p, 5, false) <= m.probability(&p, 1, false) {
+		t.Error("a faster attacker is no more likely to pass")
+	}
+	if m.probability(&p, 1, true) <= m.probability(&p, 1, false) {
+		t.Error("the slipstream does not help")
+	}
+	for _, bad := range []OvertakeModel{
+		{SpeedWeight: -1},
+		{SpeedWeight: math.NaN(), SlipstreamWeight: 1, DifficultyWeight: 1},
+		{SpeedWeight: 1, SlipstreamWeight: 1, DifficultyWeight: math.Inf(1)},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("weights %+v accepted", bad)
+		}
+	}
+}
+
+// TestOvertakes checks every attempt against the timing feed: it is
+// announced, a successful attacker crosses the line first, a failed one is
+// held up behind, and across seeds the passes made match the probabilities
+func TestOvertakes(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Laps = 30
+	var expected, variance float64
+	var passes int
+	for opts.Seed = 1; opts.Seed <= 10; opts.Seed++ {
+		result, err := GenerateSession(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		crossings := make(map[[2]int]float64) // car number and lap -> session time
+		announced := make(map[OvertakeData]bool)
+		for _, msg := range result.TimingFeed {
+			switch data := msg.Data.(type) {
+			case LapCompletedData:
+				crossings[[2]int{data.CarNumber, data.Lap}] = msg.SessionTime
+			case OvertakeData:
+				announced[data] = true
+			}
+		}
+		held := make(map[[3]int]bool) // car number, car ahead and lap
+		for _, d := range result.GroundTruth.Traffic {
+			if d.Cause == TrafficHeldUp {
+				held[[3]int{d.CarNumber, d.OtherCar, d.Lap}] = true
+			}
+		}
+
+		p := result.RaceParameters
+		for _, o := range result.GroundTruth.Overtakes {
+			if !announced[OvertakeData{o.CarNumber, o.DefendingCar, o.Lap, o.Success}] {
+				t.Errorf("seed %d: overtake %+v not in the timing feed", opts.Seed, o)
+			}
+			if want := round3(opts.overtaking().probability(&p, o.SpeedDelta, o.Slipstream)); o.Probability != want || o.SpeedDelta <= 0 {
+				t.Errorf("seed %d: overtake %+v, want probability %v", opts.Seed, o, want)
+			}
+			attacker, defender := crossings[[2]int{o.CarNumber, o.Lap}], crossings[[2]int{o.DefendingCar, o.Lap}]
+			if o.Success && attacker >= defender {
+				t.Errorf("seed %d: car %d passes car %d on lap %d but crosses at %v after %v", opts.Seed, o.CarNumber, o.DefendingCar, o.Lap, attacker, defender)
+			}
+			if !o.Success && !held[[3]int{o.CarNumber, o.DefendingCar, o.Lap}] {
+				t.Errorf("seed %d: car %d fails to pass car %d on lap %d but is not held up", opts.Seed, o.CarNumber, o.DefendingCar, o.Lap)
+			}
+
+			expected += o.Probability
+			variance += o.Probability * (1 - o.Probability)
+			if o.Success {
+				passes++
+			}
+		}
+	}
+	if math.Abs(float64(passes)-expected) > 4*math.Sqrt(variance) {
+		t.Errorf("%d passes, %.1f expected from the probabilities", passes, expected)
+	}
+}
@@ -0,0 +1,251 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParameterType is the value type of a race parameter
+type ParameterType string
+
+// Race parameter value types
+const (
+	TypeString ParameterType = "string"
+	TypeInt    ParameterType = "int"
+	TypeFloat  ParameterType = "float"
+)
+
+// Unit is the physical unit of a race parameter
+type Unit string
+
+// Race parameter units
+const (
+	UnitNone        Unit = ""
+	UnitKilometres  Unit = "km"
+	UnitMetres      Unit = "meters"
+	UnitLaps        Unit = "laps"
+	UnitSeconds     Unit = "seconds"
+	UnitKilograms   Unit = "kg"
+	UnitKgPerLap    Unit = "kg/lap"
+	UnitNewtons     Unit = "N"
+	UnitKmh         Unit = "km/h"
+	UnitCelsius     Unit = "celsius"
+	UnitPercent     Unit = "percent"
+	UnitFraction    Unit = "percentage"
+	UnitPerLap      Unit = "per_lap"
+	UnitCoefficient Unit = "coefficient"
+	UnitFactor      Unit = "factor"
+)
+
+// RaceParameters holds the track, car, weather and strategy constants of a race
+type RaceParameters struct {
+	TrackName             string
+	TrackLength           float64 // km
+	TotalLaps             int
+	BaseGrip              float64
+	TireWearRate          float64 // per lap
+	DegradationFactor     float64
+	GripCoefficient       float64
+	ReferenceLapTime      float64 // seconds
+	BaseConsumption       float64 // kg/lap
+	WeightPenalty         float64
+	BaseDrag              float64
+	DamageFactor          float64
+	BaseDownforce         float64 // N
+	AirDensityFactor      float64
+	BaseCornerSpeed       float64 // km/h
+	SlipstreamRange       float64 // meters
+	SlipstreamFactor      float64
+	TrackDifficulty       float64
+	PitLaneTime           float64 // seconds
+	TireChangeTime        float64 // seconds
+	PitLanePenalty        float64 // seconds
+	AverageGapPerPosition float64 // seconds
+	AmbientTemp           float64 // celsius
+	TrackTemp             float64 // celsius
+	Humidity              float64 // percent
+	WindSpeed             float64 // km/h
+	TireCompound          string
+	FuelCapacity          float64 // kg
+	CurrentFuel           float64 // kg
+	MaxSpeed              float64 // km/h
+	AeroDamagePercentage  float64 // fraction 0-1
+	TireAdvantagePerLap   float64 // seconds
+}
+
+// ParameterSpec describes one race parameter: its name, type, unit and valid range
+type ParameterSpec struct {
+	Name        string
+	Type        ParameterType
+	Unit        Unit
+	Description string
+	Min, Max    float64  // valid range for numeric parameters
+	Allowed     []string // valid values for string parameters, empty for any non-empty value
+
+	field func(p *RaceParameters) interface{} // pointer to the backing field
+}
+
+// parameterSchema lists every race parameter in serialization order
+var parameterSchema = []ParameterSpec{
+	{"track_name", TypeString, UnitNone, "Circuit name", 0, 0, nil, func(p *RaceParameters) interface{} { return &p.TrackName }},
+	{"track_length", TypeFloat, UnitKilometres, "Track length", 1, 10, nil, func(p *RaceParameters) interface{} { return &p.TrackLength }},
+	{"total_laps", TypeInt, UnitLaps, "Total race laps", 1, 200, nil, func(p *RaceParameters) interface{} { return &p.TotalLaps }},
+	{"base_grip", TypeFloat, UnitCoefficient, "Base tire grip level", 0, 2, nil, func(p *RaceParameters) interface{} { return &p.BaseGrip }},
+	{"tire_wear_rate", TypeFloat, UnitPerLap, "Tire degradation rate (higher for Monaco)", 0, 0.2, nil, func(p *RaceParameters) interface{} { return &p.TireWearRate }},
+	{"degradation_factor", TypeFloat, UnitFactor, "Degradation curve steepness", 0, 5, nil, func(p *RaceParameters) interface{} { return &p.DegradationFactor }},
+	{"grip_coefficient", TypeFloat, UnitCoefficient, "Grip to lap time conversion", 0, 5, nil, func(p *RaceParameters) interface{} { return &p.GripCoefficient }},
+	{"reference_lap_time", TypeFloat, UnitSeconds, "Reference lap time", 30, 200, nil, func(p *RaceParameters) interface{} { return &p.ReferenceLapTime }},
+	{"base_consumption", TypeFloat, UnitKgPerLap, "Base fuel consumption (lower for Monaco)", 0, 10, nil, func(p *RaceParameters) interface{} { return &p.BaseConsumption }},
+	{"weight_penalty", TypeFloat, UnitFactor, "Fuel weight penalty", 0, 0.01, nil, func(p *RaceParameters) interface{} { return &p.WeightPenalty }},
+	{"base_drag", TypeFloat, UnitCoefficient, "Base drag coefficient (higher downforce setup)", 0, 2, nil, func(p *RaceParameters) interface{} { return &p.BaseDrag }},
+	{"damage_factor", TypeFloat, UnitFactor, "Aero damage impact (higher risk in Monaco)", 0, 2, nil, func(p *RaceParameters) interface{} { return &p.DamageFactor }},
+	{"base_downforce", TypeFloat, UnitNewtons, "Base downforce (high downforce setup)", 0, 50000, nil, func(p *RaceParameters) interface{} { return &p.BaseDownforce }},
+	{"air_density_factor", TypeFloat, UnitFactor, "Air density correction", 0.5, 1.5, nil, func(p *RaceParameters) interface{} { return &p.AirDensityFactor }},
+	{"base_corner_speed", TypeFloat, UnitKmh, "Base cornering speed", 10, 350, nil, func(p *RaceParameters) interface{} { return &p.BaseCornerSpeed }},
+	{"slipstream_range", TypeFloat, UnitMetres, "Slipstream effective range (shorter in Monaco)", 0, 200, nil, func(p *RaceParameters) interface{} { return &p.SlipstreamRange }},
+	{"slipstream_factor", TypeFloat, UnitFactor, "Slipstream benefit (reduced in Monaco)", 0, 1, nil, func(p *RaceParameters) interface{} { return &p.SlipstreamFactor }},
+	{"track_difficulty", TypeFloat, UnitFactor, "Overtaking difficulty (very high for Monaco)", 0, 1, nil, func(p *RaceParameters) interface{} { return &p.TrackDifficulty }},
+	{"pit_lane_time", TypeFloat, UnitSeconds, "Pit lane transit time (longer for Monaco)", 0, 60, nil, func(p *RaceParameters) interface{} { return &p.PitLaneTime }},
+	{"tire_change_time", TypeFloat, UnitSeconds, "Tire change duration", 0, 30, nil, func(p *RaceParameters) interface{} { return &p.TireChangeTime }},
+	{"pit_lane_penalty", TypeFloat, UnitSeconds, "Additional pit penalty", 0, 30, nil, func(p *RaceParameters) interface{} { return &p.PitLanePenalty }},
+	{"average_gap_per_position", TypeFloat, UnitSeconds, "Time gap per position (larger in Monaco)", 0, 30, nil, func(p *RaceParameters) interface{} { return &p.AverageGapPerPosition }},
+	{"ambient_temp", TypeFloat, UnitCelsius, "Ambient temperature", -10, 50, nil, func(p *RaceParameters) interface{} { return &p.AmbientTemp }},
+	{"track_temp", TypeFloat, UnitCelsius, "Track temperature", -10, 70, nil, func(p *RaceParameters) interface{} { return &p.TrackTemp }},
+	{"humidity", TypeFloat, UnitPercent, "Relative humidity", 0, 100, nil, func(p *RaceParameters) interface{} { return &p.Humidity }},
+	{"wind_speed", TypeFloat, UnitKmh, "Wind speed (Monaco can be gusty)", 0, 100, nil, func(p *RaceParameters) interface{} { return &p.WindSpeed }},
+	{"tire_compound", TypeString, UnitNone, "Current tire compound", 0, 0, nil, func(p *RaceParameters) interface{} { return &p.TireCompound }},
+	{"fuel_capacity", TypeFloat, UnitKilograms, "Maximum fuel capacity", 0, 150, nil, func(p *RaceParameters) interface{} { return &p.FuelCapacity }},
+	{"current_fuel", TypeFloat, UnitKilograms, "Current fuel load", 0, 150, nil, func(p *RaceParameters) interface{} { return &p.CurrentFuel }},
+	{"max_speed", TypeFloat, UnitKmh, "Car maximum speed capability (Monaco limited)", 50, 400, nil, func(p *RaceParameters) interface{} { return &p.MaxSpeed }},
+	{"aero_damage_percentage", TypeFloat, UnitFraction, "Current aerodynamic damage level", 0, 1, nil, func(p *RaceParameters) interface{} { return &p.AeroDamagePercentage }},
+	{"tire_advantage_per_lap", TypeFloat, UnitSeconds, "Lap time advantage of fresh tires (higher in Monaco)", 0, 10, nil, func(p *RaceParameters) interface{} { return &p.TireAdvantagePerLap }},
+}
+
+// ParameterSchema returns the specs of all race parameters in serialization order
+func ParameterSchema() []ParameterSpec {
+	return append([]ParameterSpec(nil), parameterSchema...)
+}
+
+// MonacoParameters returns Monaco-specific race parameters
+func MonacoParameters() RaceParameters {
+	return RaceParameters{
+		TrackName:             "Monaco",
+		TrackLength:           3.337,
+		TotalLaps:             78,
+		BaseGrip:              0.95,
+		TireWearRate:          0.015,
+		DegradationFactor:     1.9,
+		GripCoefficient:       0.82,
+		ReferenceLapTime:      78.5,
+		BaseConsumption:       2.1,
+		WeightPenalty:         0.0003,
+		BaseDrag:              0.32,
+		DamageFactor:          0.25,
+		BaseDownforce:         1200,
+		AirDensityFactor:      1.0,
+		BaseCornerSpeed:       65,
+		SlipstreamRange:       30,
+		SlipstreamFactor:      0.05,
+		TrackDifficulty:       0.95,
+		PitLaneTime:           25.2,
+		TireChangeTime:        2.8,
+		PitLanePenalty:        0.8,
+		AverageGapPerPosition: 1.2,
+		AmbientTemp:           24,
+		TrackTemp:             42,
+		Humidity:              65,
+		WindSpeed:             8,
+		TireCompound:          "Medium",
+		FuelCapacity:          110,
+		CurrentFuel:           108.5,
+		MaxSpeed:              190,
+		AeroDamagePercentage:  0.03,
+		TireAdvantagePerLap:   1.2,
+	}
+}
+
+// Validate checks every parameter against its valid range
+func (p *RaceParameters) Validate() error {
+	for _, spec := range parameterSchema {
+		switch v := spec.field(p).(type) {
+		case *string:
+			if *v == "" {
+				return fmt.Errorf("race parameter %s: must not be empty", spec.Name)
+			}
+			if len(spec.Allowed) > 0 && !containsString(spec.Allowed, *v) {
+				return fmt.Errorf("race parameter %s: %q is not one of %s", spec.Name, *v, strings.Join(spec.Allowed, ", "))
+			}
+		case *int:
+			if float64(*v) < spec.Min || float64(*v) > spec.Max {
+				return fmt.Errorf("race parameter %s: %d %s outside range [%g, %g]", spec.Name, *v, spec.Unit, spec.Min, spec.Max)
+			}
+		case *float64:
+			if math.IsNaN(*v) || math.IsInf(*v, 0) {
+				return fmt.Errorf("race parameter %s: %g is not a number", spec.Name, *v)
+			}
+			if *v < spec.Min || *v > spec.Max {
+				return fmt.Errorf("race parameter %s: %g %s outside range [%g, %g]", spec.Name, *v, spec.Unit, spec.Min, spec.Max)
+			}
+		}
+	}
+	if p.CurrentFuel > p.FuelCapacity {
+		return fmt.Errorf("race parameter current_fuel: %g kg exceeds fuel_capacity %g kg", p.CurrentFuel, p.FuelCapacity)
+	}
+	return nil
+}
+
+// TireGrip returns the grip level after lapsCompleted laps on a set of tires:
+// grip_level = base_grip * (1 - tire_wear_rate * laps_completed)^degradation_factor
+func (p *RaceParameters) TireGrip(lapsCompleted float64) float64 {
+	wear := math.Max(0, 1-p.TireWearRate*lapsCompleted)
+	return p.BaseGrip * math.Pow(wear, p.DegradationFactor)
+}
+
+// TireLapTimeFactor returns the lap time multiplier of tires that have done
+// lapsCompleted laps relative to fresh tires, using
+// lap_time_impact = reference_lap_time / (1 + grip_coefficient * grip_level)
+func (p *RaceParameters) TireLapTimeFactor(lapsCompleted float64) float64 {
+	return (1 + p.GripCoefficient*p.TireGrip(0)) / (1 + p.GripCoefficient*p.TireGrip(lapsCompleted))
+}
+
+// FuelPerLap returns the fuel burned in one lap carrying fuelLoad kg:
+// fuel_per_lap = base_consumption + weight_penalty * current_fuel_load
+func (p *RaceParameters) FuelPerLap(fuelLoad float64) float64 {
+	return p.BaseConsumption + p.WeightPenalty*fuelLoad
+}
+
+// formatValue formats the parameter described by spec so that its type
+// survives a round trip: floats always carry a decimal point, ints never do
+func (p *RaceParameters) formatValue(spec ParameterSpec) string {
+	switch v := spec.field(p).(type) {
+	case *string:
+		return *v
+	case *int:
+		return strconv.Itoa(*v)
+	case *float64:
+		return formatFloat(*v)
+	}
+	return ""
+}
+
+// formatFloat formats f with the shortest exact representation, keeping a
+// decimal point so the value always reads back as a float
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
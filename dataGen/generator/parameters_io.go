@@ -0,0 +1,139 @@
+package generator
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// parameterJSON is the JSON representation of a single race parameter
+type parameterJSON struct {
+	Name        string          `json:"parameter"`
+	Type        ParameterType   `json:"type"`
+	Value       json.RawMessage `json:"value"`
+	Unit        Unit            `json:"unit"`
+	Description string          `json:"description"`
+}
+
+// jsonValue encodes the parameter described by spec as a JSON literal
+func (p *RaceParameters) jsonValue(spec ParameterSpec) json.RawMessage {
+	if spec.Type == TypeString {
+		return json.RawMessage(strconv.Quote(p.formatValue(spec)))
+	}
+	return json.RawMessage(p.formatValue(spec))
+}
+
+// WriteRaceParametersJSON writes race parameters to w as a JSON array.
+// Float values always carry a decimal point so 1200.0 and 1200 stay distinct
+func WriteRaceParametersJSON(w io.Writer, params RaceParameters) error {
+	entries := make([]parameterJSON, 0, len(parameterSchema))
+	for _, spec := range parameterSchema {
+		entries = append(entries, parameterJSON{
+			Name:        spec.Name,
+			Type:        spec.Type,
+			Value:       params.jsonValue(spec),
+			Unit:        spec.Unit,
+			Description: spec.Description,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// WriteRaceParametersYAML writes race parameters to w as a YAML mapping keyed
+// by parameter name. Strings are quoted and floats keep a decimal point so
+// YAML readers resolve every value to its declared type
+func WriteRaceParametersYAML(w io.Writer, params RaceParameters) error {
+	buf := bufio.NewWriter(w)
+	for _, spec := range parameterSchema {
+		fmt.Fprintf(buf, "%s:\n", spec.Name)
+		fmt.Fprintf(buf, "  type: %s\n", spec.Type)
+		fmt.Fprintf(buf, "  value: %s\n", params.jsonValue(spec))
+		fmt.Fprintf(buf, "  unit: %s\n", strconv.Quote(string(spec.Unit)))
+		fmt.Fprintf(buf, "  description: %s\n", strconv.Quote(spec.Description))
+	}
+	return buf.Flush()
+}
+
+// ReadRaceParametersCSV reads race parameters in the format written by
+// WriteRaceParametersCSV. Parameters missing from the file keep their
+// MonacoParameters value; the type column is optional but must match the
+// schema when present. The result is validated before it is returned
+func ReadRaceParametersCSV(r io.Reader) (RaceParameters, error) {
+	params := MonacoParameters()
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return params, err
+	}
+	if len(records) == 0 {
+		return params, errors.New("race parameters: empty file")
+	}
+
+	// Locate columns by header name
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+	nameCol, ok := columns["parameter"]
+	if !ok {
+		return params, errors.New("race parameters: missing parameter column")
+	}
+	valueCol, ok := columns["value"]
+	if !ok {
+		return params, errors.New("race parameters: missing value column")
+	}
+	typeCol, hasType := columns["type"]
+
+	specs := make(map[string]ParameterSpec, len(parameterSchema))
+	for _, spec := range parameterSchema {
+		specs[spec.Name] = spec
+	}
+
+	for line, record := range records[1:] {
+		if nameCol >= len(record) || valueCol >= len(record) {
+			return params, fmt.Errorf("race parameters line %d: too few fields", line+2)
+		}
+		spec, ok := specs[record[nameCol]]
+		if !ok {
+			return params, fmt.Errorf("race parameters line %d: unknown parameter %q", line+2, record[nameCol])
+		}
+		if hasType && typeCol < len(record) && record[typeCol] != "" && ParameterType(record[typeCol]) != spec.Type {
+			return params, fmt.Errorf("race parameter %s: type %s, expected %s", spec.Name, record[typeCol], spec.Type)
+		}
+		if err := params.setValue(spec, record[valueCol]); err != nil {
+			return params, err
+		}
+	}
+
+	return params, params.Validate()
+}
+
+// setValue parses value and stores it in the parameter described by spec
+func (p *RaceParameters) setValue(spec ParameterSpec, value string) error {
+	switch v := spec.field(p).(type) {
+	case *string:
+		*v = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("race parameter %s: %q is not an integer", spec.Name, value)
+		}
+		*v = n
+	case *float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("race parameter %s: %q is not a number", spec.Name, value)
+		}
+		*v = f
+	}
+	return nil
+}
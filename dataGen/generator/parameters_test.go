@@ -0,0 +1,20 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestReadRaceParametersRejectsNonFinite checks that values outside the
+// schema ranges, NaN and infinities included, fail validation
+func TestReadRaceParametersRejectsNonFinite(t *testing.T) {
+	for _, value := range []string{"NaN", "Inf", "-Inf", "1e9"} {
+		csv := "parameter,value\nbase_grip," + value + "\n"
+		if _, err := ReadRaceParametersCSV(strings.NewReader(csv)); err == nil {
+			t.Errorf("base_grip %s accepted", value)
+		}
+	}
+	if _, err := ReadRaceParametersCSV(strings.NewReader("parameter,value\nbase_grip,1\n")); err != nil {
+		t.Errorf("base_grip 1 rejected: %v", err)
+	}
+}
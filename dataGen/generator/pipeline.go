@@ -0,0 +1,133 @@
+package generator
+
+import (
+	"context"
+	"sync"
+)
+
+// lapResult is one generated lap handed from a worker to the merge
+type lapResult struct {
+	lap *TelemetryData
+	err error
+}
+
+// runTelemetryPipeline generates the session laps of every car on a pool of
+// workers and passes them to emit in order of lap and then car.
+//
+// Cars lose the time to traffic the timing model found. A lap's speed trace
+// depends on the lap before it, so each car's laps are planned in turn;
+// planning is cheap and the per-sample channels, which draw
+// from a random stream of their own per car and lap, run in parallel. At most
+// two laps per worker are in flight, so memory stays bounded however long
+// the session and whatever the sample rate.
+func runTelemetryPipeline(ctx context.Context, opts Options, cars []carSetup, traffic []TrafficDelay, emit func(car carSetup, lap *TelemetryData) error) error {
+	planners := make([]*lapPlanner, len(cars))
+	models := make([]*telemetryModel, len(cars))
+	for i, car := range cars {
+		models[i] = newTelemetryModel(opts, car.withTraffic(traffic))
+		planners[i] = models[i].newPlanner(newSampler(deriveSeed(opts.Seed, car.number)))
+	}
+
+	// Job i is planned lap i/len(cars) of car i%len(cars)
+	total := opts.sessionLaps() * len(cars)
+	planned := make([]chan struct{}, total)
+	results := make([]chan lapResult, total)
+	for i := range results {
+		planned[i] = make(chan struct{})
+		results[i] = make(chan lapResult, 1)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := opts.workers()
+	slots := make(chan struct{}, 2*workers)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	// Hand out jobs in order, never more than the merge can hold
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		for i := 0; i < total; i++ {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] <- runTelemetryJob(ctx, opts, i, cars, models, planners, planned)
+			}
+		}()
+	}
+
+	// Merge the laps back into order, leaving out the empty laps of retired
+	// cars and cars whose session ended early
+	err := mergeLaps(ctx, results, func(i int, lap *TelemetryData) error {
+		if lap.Len() > 0 {
+			if err := emit(cars[i%len(cars)], lap); err != nil {
+				return err
+			}
+		}
+		<-slots
+		if opts.Progress != nil {
+			opts.Progress(i+1, total)
+		}
+		return nil
+	})
+	cancel()
+	wg.Wait()
+	return err
+}
+
+// mergeLaps passes the result of each job to emit in job order
+func mergeLaps(ctx context.Context, results []chan lapResult, emit func(i int, lap *TelemetryData) error) error {
+	for i, result := range results {
+		select {
+		case r := <-result:
+			if r.err != nil {
+				return r.err
+			}
+			if err := emit(i, r.lap); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// runTelemetryJob plans job i once the car's previous lap is planned, then
+// generates its samples
+func runTelemetryJob(ctx context.Context, opts Options, i int, cars []carSetup, models []*telemetryModel, planners []*lapPlanner, planned []chan struct{}) lapResult {
+	c := i % len(cars)
+	if prev := i - len(cars); prev >= 0 {
+		select {
+		case <-planned[prev]:
+		case <-ctx.Done():
+			return lapResult{err: ctx.Err()}
+		}
+	}
+	plan := planners[c].next()
+	close(planned[i])
+
+	if err := ctx.Err(); err != nil {
+		return lapResult{err: err}
+	}
+	s := newSampler(deriveSeed(opts.Seed, cars[c].number, plan.lap))
+	return lapResult{lap: models[c].generateLap(s, plan)}
+}
@@ -0,0 +1,168 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// PitLane describes a track's pit lane. Positions are lap progress from the
+// start/finish line: the lane leaves the track at Entry, passes the line and
+// the garages and rejoins at Exit on the next lap.
+type PitLane struct {
+	Entry      float64 `json:"entry"`       // lap progress where the lane leaves the track
+	Box        float64 `json:"box"`         // lap progress of our pit box, between Entry and the line
+	Exit       float64 `json:"exit"`        // lap progress where the lane rejoins, after the line
+	SpeedLimit float64 `json:"speed_limit"` // km/h
+
+	// Stationary time model: a normal stop takes the tire change time
+	// scaled by a log-normal factor, and a slow stop adds a delay
+	StationarySpread float64 `json:"stationary_spread"` // sigma of the log-normal factor
+	SlowStopChance   float64 `json:"slow_stop_chance"`  // probability of a slow stop
+	SlowStopDelay    Range   `json:"slow_stop_delay"`   // extra seconds of a slow stop
+}
+
+// pitLanes holds the pit lane of each known track by track name
+var pitLanes = map[string]PitLane{
+	// Entry after La Rascasse, boxes along the start/finish straight and
+	// exit alongside Sainte Devote
+	"Monaco": {
+		Entry:            0.89,
+		Box:              0.97,
+		Exit:             0.1,
+		SpeedLimit:       60,
+		StationarySpread: 0.08,
+		SlowStopChance:   0.06,
+		SlowStopDelay:    Range{2, 12},
+	},
+}
+
+// pitStream identifies the random stream pit stops are drawn from
+const pitStream = -1
+
+// Validate checks that the pit lane is laid out in order around the line
+func (l PitLane) Validate() error {
+	switch {
+	case l.Entry <= 0 || l.Entry >= 1 || l.Box <= l.Entry || l.Box >= 1:
+		return fmt.Errorf("pit lane: entry %g and box %g must satisfy 0 < entry < box < 1", l.Entry, l.Box)
+	case l.Exit <= 0 || l.Exit >= l.Entry:
+		return fmt.Errorf("pit lane: exit %g must be between the line and entry %g", l.Exit, l.Entry)
+	case l.SpeedLimit <= 0:
+		return errors.New("pit lane: speed limit must be positive")
+	case l.StationarySpread < 0 || l.SlowStopChance < 0 || l.SlowStopChance > 1 || l.SlowStopDelay.Min > l.SlowStopDelay.Max:
+		return errors.New("pit lane: invalid stationary time model")
+	}
+	return nil
+}
+
+// PitStop is one generated pit stop, recorded in the ground truth
+type PitStop struct {
+	CarNumber      int     `json:"car_number"`
+	Lap            int     `json:"lap"`             // the car stops at the end of this lap
+	Compound       string  `json:"compound"`        // compound fitted
+	StationaryTime float64 `json:"stationary_time"` // seconds in the box
+	Slow           bool    `json:"slow"`            // a slow stop with an extra delay
+	LaneTime       float64 `json:"lane_time"`       // seconds from entry to exit
+	TimeLoss       float64 `json:"time_loss"`       // seconds lost against racing through
+	EstimatedLoss  float64 `json:"estimated_loss"`  // pit loss from the race parameters
+}
+
+// laneSeconds returns the time to drive the lane between two lap progress
+// positions at the speed limit
+func (l PitLane) laneSeconds(p *RaceParameters, from, to float64) float64 {
+	return (to - from) * p.TrackLength * 3600 / l.SpeedLimit
+}
+
+// estimatedPitLoss returns the pit loss the race parameters predict
+func estimatedPitLoss(p *RaceParameters) float64 {
+	return p.PitLaneTime + p.TireChangeTime + p.PitLanePenalty
+}
+
+// ourPitLaps returns the laps at the end of which our car stops
+func (o Options) ourPitLaps() []int {
+	if o.PitLaps != nil {
+		laps := append([]int(nil), o.PitLaps...)
+		sort.Ints(laps)
+		return laps
+	}
+	return tirePitLaps(0, o.Laps)
+}
+
+// tirePitLaps returns the stops of a car starting on tires tireAge laps old:
+// cars whose tires would pass 28 laps stop once within the session
+func tirePitLaps(tireAge, totalLaps int) []int {
+	if tireAge+totalLaps <= 28 || totalLaps < 2 {
+		return nil
+	}
+	return []int{clampInt(30-tireAge, 1, totalLaps-1)}
+}
+
+// planPitStops draws the stops of car at the given laps, starting on
+// compound and fitting the fitted compounds in order. Stops beyond fitted
+// change to a different compound at random. Stops come from a random stream
+// of the car's own, so telemetry, timing and ground truth all see the same
+// stops. Practice and qualifying have no pit stops, cars go back to the
+// garage between runs instead
+func planPitStops(opts Options, carNumber int, laps []int, fitted []string, compound string) []PitStop {
+	if len(laps) == 0 || !opts.racing() {
+		return nil
+	}
+	p, lane := opts.parameters(), opts.pitLane()
+	names := compoundNames(opts.compounds())
+	s := newSampler(deriveSeed(opts.Seed, carNumber, pitStream))
+
+	// Time through the lane against the same stretch at racing speed
+	driving := lane.laneSeconds(p, lane.Entry, 1) + lane.laneSeconds(p, 0, lane.Exit)
+	racing := (1 - lane.Entry + lane.Exit) * p.ReferenceLapTime
+
+	stops := make([]PitStop, 0, len(laps))
+	for i, lap := range laps {
+		if i < len(fitted) {
+			compound = fitted[i]
+		} else {
+			compound = otherCompound(s, names, compound)
+		}
+		stationary := p.TireChangeTime * math.Exp(s.normalRandom(0, lane.StationarySpread))
+		slow := s.float64() < lane.SlowStopChance
+		if slow {
+			stationary += s.uniformRandom(lane.SlowStopDelay.Min, lane.SlowStopDelay.Max)
+		}
+		stops = append(stops, PitStop{
+			CarNumber:      carNumber,
+			Lap:            lap,
+			Compound:       compound,
+			StationaryTime: round3(stationary),
+			Slow:           slow,
+			LaneTime:       round3(driving + stationary),
+			TimeLoss:       round3(driving + stationary - racing),
+			EstimatedLoss:  round3(estimatedPitLoss(p)),
+		})
+	}
+	return stops
+}
+
+// otherCompound draws a compound from names other than current, if there
+// is one
+func otherCompound(s *sampler, names []string, current string) string {
+	var others []string
+	for _, name := range names {
+		if name != current {
+			others = append(others, name)
+		}
+	}
+	if len(others) == 0 {
+		return current
+	}
+	return others[s.intn(len(others))]
+}
+
+// pitStopAt returns the stop made at the end of lap, if any
+func pitStopAt(stops []PitStop, lap int) (PitStop, bool) {
+	for _, stop := range stops {
+		if stop.Lap == lap {
+			return stop, true
+		}
+	}
+	return PitStop{}, false
+}
@@ -0,0 +1,57 @@
+package generator
+
+import (
+	"math"
+	"testing"
+)
+
+// TestPitLossEstimate checks the pit loss the race parameters predict
+// against the stops the generator draws, normal and slow, and that the
+// telemetry spends the drawn time in the pit lane
+func TestPitLossEstimate(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Laps = 3
+	opts.PitLaps = []int{1}
+	opts.EngineFailures = []EngineFailure{}
+	estimate := estimatedPitLoss(opts.parameters())
+	spread := opts.pitLane().SlowStopDelay
+
+	var normal, slow []PitStop
+	for seed := int64(1); seed <= 300; seed++ {
+		opts.Seed = seed
+		stop := ourCar(opts, nil).stops[0]
+		if stop.Slow {
+			slow = append(slow, stop)
+		} else {
+			normal = append(normal, stop)
+		}
+
+		// The laps into and out of the lane run long by the stop's loss
+		model := newTelemetryModel(opts, ourCar(opts, nil))
+		planner := model.newPlanner(newSampler(seed))
+		samples := 0
+		for lap := 1; lap <= opts.Laps; lap++ {
+			samples += len(planner.next().speeds)
+		}
+		extra := float64(samples-opts.Laps*model.samplesPerLap) / model.sampleRate
+		if math.Abs(extra-stop.TimeLoss) > 0.5 {
+			t.Errorf("seed %d: telemetry loses %.2fs in the pit lane, stop loses %.2fs", seed, extra, stop.TimeLoss)
+		}
+	}
+	if len(slow) == 0 || len(normal) == 0 {
+		t.Fatalf("%d normal and %d slow stops, want both", len(normal), len(slow))
+	}
+
+	var mean float64
+	for _, stop := range normal {
+		mean += stop.TimeLoss / float64(len(normal))
+		if math.Abs(stop.TimeLoss-estimate) > 0.1*estimate {
+			t.Errorf("normal stop loses %.2fs, estimate %.2fs", stop.TimeLoss, estimate)
+		}
+	}
+	for _, stop := range slow {
+		if stop.TimeLoss < mean+0.8*spread.Min {
+			t.Errorf("slow stop loses %.2fs, normal stops %.2fs on average", stop.TimeLoss, mean)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/quick"
+)
+
+// propertyRun is a randomly chosen generation run for property tests
+type propertyRun struct {
+	Seed         int64
+	Session      string
+	Laps         int
+	SampleRate   float64
+	Driver       string
+	GridSize     int
+	CarNumber    int
+	GridPosition int
+	PitLap       int // lap our car stops after, 0 for the tire age rule
+	FailureLap   int // lap our car's engine starts failing on, 0 for a reliable engine
+}
+
+// Generate implements quick.Generator, keeping runs small enough to be quick
+func (propertyRun) Generate(r *rand.Rand, size int) reflect.Value {
+	sessions := SessionTypes()
+	rates := []float64{1, 10, 50, 100, 1000}
+	drivers := DriverProfileNames()
+	gridSize := 2 + r.Intn(MaxGridSize-1)
+	laps := 1 + r.Intn(3)
+	return reflect.ValueOf(propertyRun{
+		Seed:         r.Int63(),
+		Session:      sessions[r.Intn(len(sessions))],
+		Laps:         laps,
+		SampleRate:   rates[r.Intn(len(rates))],
+		Driver:       drivers[r.Intn(len(drivers))],
+		GridSize:     gridSize,
+		CarNumber:    1 + r.Intn(MaxCarNumber),
+		GridPosition: 1 + r.Intn(gridSize),
+		PitLap:       r.Intn(laps),
+		FailureLap:   r.Intn(laps + 1),
+	})
+}
+
+// options returns the generator options of the run
+func (run propertyRun) options() Options {
+	driver, _ := DriverProfile(run.Driver)
+	var pitLaps []int
+	if run.PitLap > 0 && (Options{Session: run.Session}).racing() {
+		pitLaps = []int{run.PitLap}
+	}
+	failures := []EngineFailure{}
+	if run.FailureLap > 0 {
+		failures = append(failures, EngineFailure{Mode: FailureOilLeak, Lap: run.FailureLap, Progress: 0.2, Laps: 0.5})
+	}
+	return Options{
+		Seed:           run.Seed,
+		Session:        run.Session,
+		Laps:           run.Laps,
+		SampleRate:     run.SampleRate,
+		Driver:         &driver,
+		GridSize:       run.GridSize,
+		CarNumber:      run.CarNumber,
+		GridPosition:   run.GridPosition,
+		PitLaps:        pitLaps,
+		EngineFailures: failures,
+	}
+}
+
+// checkProperty runs property against random runs, reporting the first
+// violation it returns
+func checkProperty(t *testing.T, property func(run propertyRun, result *Result) string) {
+	t.Helper()
+	f := func(run propertyRun) bool {
+		result, err := Generate(context.Background(), run.options())
+		if err != nil {
+			t.Errorf("%+v: %v", run, err)
+			return false
+		}
+		if violation := property(run, result); violation != "" {
+			t.Errorf("%+v: %s", run, violation)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 12}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTelemetryTimeStrictlyIncreasing(t *testing.T) {
+	checkProperty(t, func(run propertyRun, result *Result) string {
+		d := result.Telemetry
+		for i := 1; i < d.Len(); i++ {
+			if d.Time[i] <= d.Time[i-1] {
+				return fmt.Sprintf("time %v at sample %d follows %v", d.Time[i], i, d.Time[i-1])
+			}
+		}
+		return ""
+	})
+}
+
+func TestTelemetryLapMatchesDistance(t *testing.T) {
+	checkProperty(t, func(run propertyRun, result *Result) string {
+		d := result.Telemetry
+		trackLength := result.RaceParameters.TrackLength
+		for i := 0; i < d.Len(); i++ {
+			lapStart := float64(d.Lap[i]-1) * trackLength
+			from, to := lapStart-1e-9, lapStart+trackLength-1e-9
+			// A race starts lap 1 from the grid slots behind the line, where
+			// the formation lap ends, on the line itself for pole
+			if run.Session == SessionRace && d.Lap[i] == 1 {
+				from -= float64(run.GridSize) * gridSlotSpacing / 1000
+			}
+			if run.Session == SessionRace && d.Lap[i] == 0 {
+				to += 2e-9
+			}
+			if d.Distance[i] < from || d.Distance[i] >= to {
+				return fmt.Sprintf("distance %v at sample %d is outside lap %d", d.Distance[i], i, d.Lap[i])
+			}
+		}
+		return ""
+	})
+}
+
+func TestTelemetryChannelRanges(t *testing.T) {
+	checkProperty(t, func(run propertyRun, result *Result) string {
+		d := result.Telemetry
+		maxSpeed := result.RaceParameters.MaxSpeed
+		failure := planEngineFailure(run.options(), run.options().carNumber(), run.options().EngineFailures)
+		for i := 0; i < d.Len(); i++ {
+			progress := d.Distance[i]/result.RaceParameters.TrackLength - float64(d.Lap[i]-1)
+			retiring := failure.retiredBy(d.Lap[i], progress)
+			// A race forms up on the grid and starts from rest
+			standing := run.Session == SessionRace && (d.Lap[i] == 0 || d.Lap[i] == 1 && progress < 0.1)
+			switch {
+			case d.Gear[i] < 1 || d.Gear[i] > 8:
+				return fmt.Sprintf("gear %d at sample %d", d.Gear[i], i)
+			case d.PitLimiter[i] == 0 && !retiring && !standing && d.Speed[i] < 20 || d.Speed[i] < 0 || d.Speed[i] > maxSpeed:
+				return fmt.Sprintf("speed %v at sample %d", d.Speed[i], i)
+			case d.Throttle[i] < 0 || d.Throttle[i] > 100:
+				return fmt.Sprintf("throttle %v at sample %d", d.Throttle[i], i)
+			case d.BrakePressure[i] < 0:
+				return fmt.Sprintf("brake pressure %v at sample %d", d.BrakePressure[i], i)
+			case d.FuelFlow[i] < 0 || d.FuelFlow[i] > 110:
+				return fmt.Sprintf("fuel flow %v at sample %d", d.FuelFlow[i], i)
+			case d.EngineRPM[i] < 5000 || d.EngineRPM[i] > 15000:
+				return fmt.Sprintf("engine rpm %d at sample %d", d.EngineRPM[i], i)
+			case d.DRSActive[i] != 0 && d.DRSActive[i] != 1:
+				return fmt.Sprintf("drs %d at sample %d", d.DRSActive[i], i)
+			case d.PitLimiter[i] == 1 && d.Speed[i] > run.options().pitLane().SpeedLimit:
+				return fmt.Sprintf("speed %v on the pit limiter at sample %d", d.Speed[i], i)
+			case d.BrakeBias[i] < minBrakeBias || d.BrakeBias[i] > maxBrakeBias:
+				return fmt.Sprintf("brake bias %v at sample %d", d.BrakeBias[i], i)
+			case d.OilTemp[i] < 60 || d.OilTemp[i] > 170 || d.WaterTemp[i] < 60 || d.WaterTemp[i] > 150:
+				return fmt.Sprintf("oil temperature %v and water temperature %v at sample %d", d.OilTemp[i], d.WaterTemp[i], i)
+			case d.OilPressure[i] < 0 || d.OilPressure[i] > 8:
+				return fmt.Sprintf("oil pressure %v at sample %d", d.OilPressure[i], i)
+			case d.TurboSpeed[i] < 0 || d.TurboSpeed[i] > 130000:
+				return fmt.Sprintf("turbo speed %d at sample %d", d.TurboSpeed[i], i)
+			}
+			for _, temp := range []float64{d.BrakeTempFL[i], d.BrakeTempFR[i], d.BrakeTempRL[i], d.BrakeTempRR[i]} {
+				if temp < result.RaceParameters.TrackTemp || temp > maxBrakeTemp {
+					return fmt.Sprintf("brake temperature %v at sample %d", temp, i)
+				}
+			}
+			for _, temp := range []float64{d.TireTempFL[i], d.TireTempFR[i], d.TireTempRL[i], d.TireTempRR[i]} {
+				if temp < 80 || temp > 140 {
+					return fmt.Sprintf("tire temperature %v at sample %d", temp, i)
+				}
+			}
+		}
+		return ""
+	})
+}
+
+func TestCompetitorPositions(t *testing.T) {
+	checkProperty(t, func(run propertyRun, result *Result) string {
+		var positions, cars []int
+		ourRows := 0
+		for _, c := range result.Competitors {
+			positions = append(positions, c.Position)
+			cars = append(cars, c.CarNumber)
+			if !c.OurCar {
+				continue
+			}
+			ourRows++
+			if c.CarNumber != run.CarNumber || c.Position != run.GridPosition {
+				return fmt.Sprintf("our car row is car %d in P%d", c.CarNumber, c.Position)
+			}
+		}
+		if ourRows != 1 {
+			return fmt.Sprintf("%d rows flagged as our car", ourRows)
+		}
+		sort.Ints(positions)
+		for i, position := range positions {
+			if position != i+1 {
+				return fmt.Sprintf("positions %v are not 1..%d", positions, run.GridSize)
+			}
+		}
+		sort.Ints(cars)
+		for i := 1; i < len(cars); i++ {
+			if cars[i] == cars[i-1] {
+				return fmt.Sprintf("car number %d used twice", cars[i])
+			}
+		}
+		return ""
+	})
+}
+
+func TestCompetitorGapsMonotonic(t *testing.T) {
+	checkProperty(t, func(run propertyRun, result *Result) string {
+		competitors := append([]Competitor(nil), result.Competitors...)
+		sort.Slice(competitors, func(i, j int) bool { return competitors[i].Position < competitors[j].Position })
+		leader := competitors[0]
+		if leader.GapToLeader != 0 || leader.Interval != 0 || leader.LapsDown != 0 {
+			return fmt.Sprintf("leader gap %v, interval %v, laps down %d", leader.GapToLeader, leader.Interval, leader.LapsDown)
+		}
+		for i := 1; i < len(competitors); i++ {
+			c, ahead := competitors[i], competitors[i-1]
+			if c.GapToLeader < ahead.GapToLeader {
+				return fmt.Sprintf("P%d gap %v is less than P%d gap %v", c.Position, c.GapToLeader, ahead.Position, ahead.GapToLeader)
+			}
+			if math.Abs(c.Interval-(c.GapToLeader-ahead.GapToLeader)) > 0.011 {
+				return fmt.Sprintf("P%d interval %v does not match gaps %v and %v", c.Position, c.Interval, ahead.GapToLeader, c.GapToLeader)
+			}
+			if c.LapsDown < ahead.LapsDown {
+				return fmt.Sprintf("P%d is %d laps down, fewer than P%d", c.Position, c.LapsDown, ahead.Position)
+			}
+		}
+		return ""
+	})
+}
+
+func TestTimingFeedGapsMonotonic(t *testing.T) {
+	checkProperty(t, func(run propertyRun, result *Result) string {
+		// Practice and qualifying cars run laps at their own times
+		if !run.options().racing() {
+			return ""
+		}
+		// The nth gap update of a car belongs to its nth lap
+		laps := map[int]int{}
+		byLap := map[int][]GapUpdateData{}
+		for _, msg := range result.TimingFeed {
+			gap, ok := msg.Data.(GapUpdateData)
+			if !ok {
+				continue
+			}
+			laps[gap.CarNumber]++
+			byLap[laps[gap.CarNumber]] = append(byLap[laps[gap.CarNumber]], gap)
+		}
+		for lap, gaps := range byLap {
+			sort.Slice(gaps, func(i, j int) bool { return gaps[i].Position < gaps[j].Position })
+			for i, gap := range gaps {
+				if gap.Position != i+1 {
+					return fmt.Sprintf("lap %d positions %v are not 1..%d", lap, gaps, len(gaps))
+				}
+				if i == 0 && gap.GapToLeader != 0 {
+					return fmt.Sprintf("lap %d leader gap %v", lap, gap.GapToLeader)
+				}
+				if i > 0 && gap.GapToLeader < gaps[i-1].GapToLeader {
+					return fmt.Sprintf("lap %d P%d gap %v is less than the car ahead", lap, gap.Position, gap.GapToLeader)
+				}
+				if i > 0 && math.Abs(gap.Interval-(gap.GapToLeader-gaps[i-1].GapToLeader)) > 0.002 {
+					return fmt.Sprintf("lap %d P%d interval %v does not match gaps", lap, gap.Position, gap.Interval)
+				}
+			}
+		}
+		return ""
+	})
+}
+
+func TestCompetitorLappedCars(t *testing.T) {
+	params := MonacoParameters()
+	params.AverageGapPerPosition = 8
+	competitors := generateCompetitorData(newSampler(1), &params, DefaultOptions())
+
+	var leaderLapTime float64
+	for _, c := range competitors {
+		if c.Position == 1 {
+			leaderLapTime = c.LastLapTime
+		}
+	}
+
+	lapped := 0
+	for _, c := range competitors {
+		wantLapsDown := int(c.GapToLeader / leaderLapTime)
+		if c.LapsDown < wantLapsDown-1 || c.LapsDown > wantLapsDown+1 {
+			t.Errorf("car %d with gap %v is %d laps down", c.CarNumber, c.GapToLeader, c.LapsDown)
+		}
+		if c.LapsDown > 0 {
+			lapped++
+		}
+		if c.DistanceToOurCar < 0 || c.DistanceToOurCar > params.TrackLength*500 {
+			t.Errorf("car %d is %v m from our car, more than half a lap", c.CarNumber, c.DistanceToOurCar)
+		}
+	}
+	if lapped == 0 {
+		t.Error("no car is lapped with 8 s between positions")
+	}
+}
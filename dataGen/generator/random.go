@@ -0,0 +1,107 @@
+package generator
+
+import (
+	"math"
+	"math/rand"
+)
+
+// sampler wraps a seeded random source so every generation run is
+// reproducible and independent of other runs
+type sampler struct {
+	rng *rand.Rand
+}
+
+// newSampler creates a sampler seeded with seed
+func newSampler(seed int64) *sampler {
+	return &sampler{rng: rand.New(rand.NewSource(seed))}
+}
+
+// normalRandom generates a normally distributed random number
+func (s *sampler) normalRandom(mean, stddev float64) float64 {
+	return s.rng.NormFloat64()*stddev + mean
+}
+
+// uniformRandom generates a uniform random number between min and max
+func (s *sampler) uniformRandom(min, max float64) float64 {
+	return min + s.rng.Float64()*(max-min)
+}
+
+// intn returns a uniform random integer in [0, n)
+func (s *sampler) intn(n int) int {
+	return s.rng.Intn(n)
+}
+
+// float64 returns a uniform random number in [0, 1)
+func (s *sampler) float64() float64 {
+	return s.rng.Float64()
+}
+
+// deriveSeed returns the seed of one independent random stream of a run,
+// identified by ids such as a car number and lap. Workers each draw from
+// their own stream, so the output does not depend on how they are scheduled.
+func deriveSeed(seed int64, ids ...int) int64 {
+	x := uint64(seed)
+	for _, id := range ids {
+		x = splitMix64(x ^ splitMix64(uint64(id)+1))
+	}
+	return int64(x)
+}
+
+// splitMix64 is the SplitMix64 finaliser, a cheap bijective bit mixer
+func splitMix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// clamp constrains a value between min and max
+func clamp(value, min, max float64) float64 {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+// clampInt constrains an integer between min and max
+func clampInt(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+// ouProcess is an Ornstein–Uhlenbeck process: zero-mean noise with a
+// stationary standard deviation sigma that decorrelates over tau seconds
+type ouProcess struct {
+	value float64
+	sigma float64
+	decay float64 // exp(-dt/tau)
+	scale float64 // sigma * sqrt(1 - decay²)
+}
+
+// newOUProcess creates an OU process sampled every dt seconds
+func newOUProcess(sigma, tau, dt float64) *ouProcess {
+	decay := 0.0
+	if tau > 0 {
+		decay = math.Exp(-dt / tau)
+	}
+	return &ouProcess{sigma: sigma, decay: decay, scale: sigma * math.Sqrt(1-decay*decay)}
+}
+
+// start draws the current value from the stationary distribution
+func (o *ouProcess) start(s *sampler) {
+	o.value = s.normalRandom(0, o.sigma)
+}
+
+// next advances the process by one sample and returns its value
+func (o *ouProcess) next(s *sampler) float64 {
+	o.value = o.value*o.decay + s.normalRandom(0, o.scale)
+	return o.value
+}
@@ -0,0 +1,593 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Session types
+const (
+	SessionRun        = "run"        // a continuous run of racing laps
+	SessionPractice   = "practice"   // runs from the garage with time in between
+	SessionQualifying = "qualifying" // push laps in three segments with eliminations
+	SessionRace       = "race"       // a formation lap and a standing start
+)
+
+// SessionTypes returns the names of the session types
+func SessionTypes() []string {
+	return []string{SessionRun, SessionPractice, SessionQualifying, SessionRace}
+}
+
+// Lap types, as written to the lap summaries
+const (
+	LapRacing    = "racing"
+	LapFormation = "formation" // from the grid back to the grid
+	LapOut       = "out"       // from the garage, warming the tires
+	LapPush      = "push"      // a qualifying attempt
+	LapCoolDown  = "cool_down" // between push laps, recharging the battery
+	LapIn        = "in"        // back to the garage
+)
+
+// lapPace is the fraction of racing speed each lap type is driven at
+var lapPace = map[string]float64{
+	LapRacing:    1,
+	LapFormation: 0.45,
+	LapOut:       0.8,
+	LapPush:      1,
+	LapCoolDown:  0.7,
+	LapIn:        0.85,
+}
+
+// Session formats
+const (
+	practiceLength   = 3600 // s
+	practiceShortRun = 25   // kg of fuel for runs of up to 4 flying laps
+	practiceLongRun  = 70   // kg of fuel for longer runs
+	qualifyingFuel   = 12   // kg, enough for one run
+	segmentBreak     = 480  // s between qualifying segments
+	garageStop       = 2    // s stationary at the garage at the end of an in-lap
+	practiceStream   = -4   // random stream practice runs are drawn from
+	qualifyingStream = -5   // random stream qualifying is drawn from
+)
+
+// Session format ranges
+var (
+	segmentLengths     = [...]float64{1080, 900, 720} // s of Q1, Q2 and Q3
+	practiceGarage     = Range{240, 900}              // s in the garage between runs
+	practiceFlyingLaps = Range{2, 8}
+)
+
+// qualifyingLaps are the laps of a qualifying run, in order
+var qualifyingLaps = []string{LapOut, LapPush, LapCoolDown, LapPush, LapIn}
+
+// sessionLap is one lap of a car's session plan
+type sessionLap struct {
+	lap      int
+	kind     string  // lap type, LapRacing and so on
+	standing bool    // the lap starts from a standstill on the grid
+	newRun   bool    // the lap starts from the garage on fresh tires
+	start    float64 // session time the car leaves the garage, on new runs
+	fuel     float64 // kg at the start of a new run
+	compound string  // fitted for a new run
+	lapTime  float64 // s the timing model gives the lap, zero when it has not timed it
+}
+
+// validateSession checks the session type and the options that only apply
+// to some sessions
+func (o Options) validateSession() error {
+	switch o.session() {
+	case SessionRun, SessionRace:
+		return nil
+	case SessionPractice, SessionQualifying:
+		if o.PitLaps != nil || o.PitCompounds != nil {
+			return fmt.Errorf("generator: pit laps do not apply to %s sessions", o.session())
+		}
+		return nil
+	}
+	return fmt.Errorf("generator: unknown session type %q", o.Session)
+}
+
+// session returns the session type
+func (o Options) session() string {
+	if o.Session != "" {
+		return o.Session
+	}
+	return SessionRun
+}
+
+// sessionLaps returns the number of telemetry laps of each car: Laps, and
+// the formation lap of a race
+func (o Options) sessionLaps() int {
+	if o.session() == SessionRace {
+		return o.Laps + 1
+	}
+	return o.Laps
+}
+
+// racing reports whether cars race through the whole session, making pit
+// stops, rather than running from the garage
+func (o Options) racing() bool {
+	return o.session() == SessionRun || o.session() == SessionRace
+}
+
+// lapSamples returns the number of samples of a lap of type kind without the
+// pit lane, driven slower than racing speed and so for longer
+func lapSamples(p *RaceParameters, sampleRate float64, kind string) int {
+	return int(float64(int(p.ReferenceLapTime*sampleRate)) / lapPace[kind])
+}
+
+// formationLapTime returns the seconds of the formation lap
+func formationLapTime(opts Options) float64 {
+	return float64(lapSamples(opts.parameters(), opts.sampleRate(), LapFormation)) / opts.sampleRate()
+}
+
+// sessionPlans holds the laps of every car over the session, planned once
+// so the timing feed and every car's telemetry share one qualifying
+type sessionPlans struct {
+	laps      map[int][]sessionLap // by car number
+	qualified []qualifyingCar      // in competitor order, qualifying only
+}
+
+// planSession plans the session of every car in competitors
+func planSession(opts Options, competitors []Competitor) *sessionPlans {
+	plans := &sessionPlans{laps: make(map[int][]sessionLap, len(competitors))}
+	if opts.session() == SessionQualifying {
+		plans.qualified = simulateQualifying(opts, competitors)
+		for _, car := range plans.qualified {
+			plans.laps[car.carNumber] = sessionPlan(opts, car.carNumber, car.runs)
+		}
+		return plans
+	}
+	for _, c := range competitors {
+		plans.laps[c.CarNumber] = sessionPlan(opts, c.CarNumber, nil)
+	}
+	return plans
+}
+
+// carLaps returns the laps of car over the session. Without plans, for a
+// car planned on its own, qualifying is simulated from the competitors of
+// opts
+func (p *sessionPlans) carLaps(opts Options, carNumber int) []sessionLap {
+	if p == nil && opts.session() != SessionQualifying {
+		return sessionPlan(opts, carNumber, nil)
+	}
+	if p == nil {
+		p = planSession(opts, generateCompetitorData(newSampler(opts.Seed), opts.parameters(), opts))
+	}
+	return p.laps[carNumber]
+}
+
+// setLapTime records the time the timing model gives lap of car, less any
+// time lost at the start or in traffic, for its telemetry to take
+func (p *sessionPlans) setLapTime(carNumber, lap int, lapTime float64) {
+	laps := p.laps[carNumber]
+	for i := range laps {
+		if laps[i].lap == lap {
+			laps[i].lapTime = lapTime
+		}
+	}
+}
+
+// sessionPlan returns the laps of car over the session, numbered from 1
+// with a race's formation lap as lap 0. In qualifying the car makes runs
+func sessionPlan(opts Options, carNumber int, runs []qualifyingRun) []sessionLap {
+	var laps []sessionLap
+	switch opts.session() {
+	case SessionPractice:
+		return practicePlan(opts, carNumber)
+	case SessionQualifying:
+		return qualifyingPlan(opts, runs)
+	case SessionRace:
+		laps = append(laps, sessionLap{lap: 0, kind: LapFormation, standing: true})
+	}
+	for lap := 1; lap <= opts.Laps; lap++ {
+		laps = append(laps, sessionLap{lap: lap, kind: LapRacing, standing: lap == 1 && opts.session() == SessionRace})
+	}
+	return laps
+}
+
+// practiceRun is one run of a car in practice
+type practiceRun struct {
+	start    float64 // session time the car leaves the garage
+	laps     []string
+	fuel     float64
+	compound string
+}
+
+// practiceRuns draws the runs of car over a practice session of at most
+// opts.Laps laps from the car's own stream: an out-lap, a few flying laps
+// on a fresh set and an in-lap, with time in the garage between runs
+func practiceRuns(opts Options, carNumber int) []practiceRun {
+	p := opts.parameters()
+	names := compoundNames(opts.compounds())
+	s := newSampler(deriveSeed(opts.Seed, carNumber, practiceStream))
+
+	var runs []practiceRun
+	remaining := opts.Laps
+	start := s.uniformRandom(120, 900)
+	for remaining > 0 && start < practiceLength {
+		flying := int(s.uniformRandom(practiceFlyingLaps.Min, practiceFlyingLaps.Max+1))
+		run := practiceRun{start: start, fuel: practiceShortRun, compound: names[s.intn(len(names))]}
+		if flying > 4 {
+			run.fuel = practiceLongRun
+		}
+		run.fuel = math.Min(run.fuel, p.FuelCapacity)
+		run.laps = append(run.laps, LapOut)
+		for i := 0; i < flying; i++ {
+			run.laps = append(run.laps, LapRacing)
+		}
+		run.laps = append(run.laps, LapIn)
+		if len(run.laps) > remaining {
+			run.laps = run.laps[:remaining]
+		}
+		remaining -= len(run.laps)
+		runs = append(runs, run)
+
+		for _, kind := range run.laps {
+			start += p.ReferenceLapTime / lapPace[kind]
+		}
+		start += s.uniformRandom(practiceGarage.Min, practiceGarage.Max)
+	}
+	return runs
+}
+
+// practicePlan returns the laps of car over a practice session
+func practicePlan(opts Options, carNumber int) []sessionLap {
+	var laps []sessionLap
+	for _, run := range practiceRuns(opts, carNumber) {
+		for i, kind := range run.laps {
+			lap := sessionLap{lap: len(laps) + 1, kind: kind}
+			if i == 0 {
+				lap.newRun, lap.start, lap.fuel, lap.compound = true, run.start, run.fuel, run.compound
+			}
+			laps = append(laps, lap)
+		}
+	}
+	return laps
+}
+
+// qualifyingRun is one run of a car in a qualifying segment, with the laps
+// of qualifyingLaps
+type qualifyingRun struct {
+	segment  int     // 1 to 3
+	start    float64 // session time the car leaves the garage
+	lapTimes []float64
+}
+
+// qualifyingCar is one car's qualifying session
+type qualifyingCar struct {
+	carNumber  int
+	runs       []qualifyingRun
+	best       [3]float64 // best push lap of each segment, zero when not run
+	eliminated int        // segment the car dropped out in, zero when it reached the last
+	position   int        // final classification
+}
+
+// segmentStartTime returns the session time qualifying segment starts
+func segmentStartTime(segment int) float64 {
+	var start float64
+	for i := 1; i < segment; i++ {
+		start += segmentLengths[i-1] + segmentBreak
+	}
+	return start
+}
+
+// simulateQualifying runs qualifying for the whole field from its own
+// random stream, so the timing feed and every car's telemetry agree on who
+// is eliminated where. Each car makes one run per segment while it has laps
+// of opts.Laps left, and the slowest quarter of the field drops out after
+// each of the first two. Cars without a time in a segment keep their order
+// from the one before
+func simulateQualifying(opts Options, competitors []Competitor) []qualifyingCar {
+	p := opts.parameters()
+	tires := opts.tireModel()
+	soft := compoundNames(opts.compounds())[0]
+	s := newSampler(deriveSeed(opts.Seed, qualifyingStream))
+	fuelGain := fuelEffect(p, p.CurrentFuel) / fuelEffect(p, qualifyingFuel)
+
+	cars := make([]qualifyingCar, len(competitors))
+	laps := make([]int, len(competitors))
+	drivers := make([]Driver, len(competitors))
+	paces := make([]float64, len(competitors))
+	for i, c := range competitors {
+		drivers[i] = competitorDriver(opts, c)
+		paces[i] = c.LastLapTime / tires.lastLapFactor(c.TireCompound, drivers[i], c.TireAge)
+		cars[i].carNumber = c.CarNumber
+	}
+
+	running := make([]int, len(cars))
+	for i := range running {
+		running[i] = i
+	}
+	var classified []int
+	eliminate := len(cars) / 4
+	for segment := 1; segment <= len(segmentLengths); segment++ {
+		length := segmentLengths[segment-1]
+		for _, i := range running {
+			kinds := qualifyingLaps[:min(len(qualifyingLaps), opts.Laps-laps[i])]
+			if len(kinds) == 0 {
+				continue
+			}
+			laps[i] += len(kinds)
+			pushFactor, _ := tires.lap(soft, drivers[i], 1, 1)
+			push := paces[i] * pushFactor * fuelGain
+			run := qualifyingRun{segment: segment}
+			var duration float64
+			for _, kind := range kinds {
+				lapTime := push/lapPace[kind] + s.normalRandom(0, drivers[i].lapTimeSigma(push))
+				for range monacoCorners {
+					if kind == LapPush && s.float64() < drivers[i].MistakeProbability {
+						lapTime += s.uniformRandom(0.5, 2.5) // Lock-up or missed apex
+					}
+				}
+				run.lapTimes = append(run.lapTimes, round3(lapTime))
+				duration += lapTime
+				if kind == LapPush && (cars[i].best[segment-1] == 0 || lapTime < cars[i].best[segment-1]) {
+					cars[i].best[segment-1] = round3(lapTime)
+				}
+			}
+			run.start = round3(segmentStartTime(segment) + s.uniformRandom(30, math.Max(length-duration, 60)))
+			cars[i].runs = append(cars[i].runs, run)
+		}
+
+		sort.SliceStable(running, func(a, b int) bool {
+			return timeOrInf(cars[running[a]].best[segment-1]) < timeOrInf(cars[running[b]].best[segment-1])
+		})
+		if segment == len(segmentLengths) {
+			classified = append(append([]int(nil), running...), classified...)
+			break
+		}
+		out := running[len(running)-eliminate:]
+		for _, i := range out {
+			cars[i].eliminated = segment
+		}
+		classified = append(append([]int(nil), out...), classified...)
+		running = running[:len(running)-eliminate]
+	}
+	for position, i := range classified {
+		cars[i].position = position + 1
+	}
+	return cars
+}
+
+// timeOrInf returns lapTime, or +Inf for no time set
+func timeOrInf(lapTime float64) float64 {
+	if lapTime == 0 {
+		return math.Inf(1)
+	}
+	return lapTime
+}
+
+// qualifyingPlan returns the laps of a car's qualifying runs
+func qualifyingPlan(opts Options, runs []qualifyingRun) []sessionLap {
+	soft := compoundNames(opts.compounds())[0]
+	var laps []sessionLap
+	for _, run := range runs {
+		for i := range run.lapTimes {
+			kind := qualifyingLaps[i]
+			lap := sessionLap{lap: len(laps) + 1, kind: kind, lapTime: run.lapTimes[i]}
+			if i == 0 {
+				lap.newRun, lap.start, lap.fuel, lap.compound = true, run.start, qualifyingFuel, soft
+			}
+			laps = append(laps, lap)
+		}
+	}
+	return laps
+}
+
+// timedRun is one run of a car from the garage as the timing feed sees it
+type timedRun struct {
+	segment  int // qualifying segment, zero in practice
+	start    float64
+	kinds    []string
+	lapTimes []float64
+	compound string
+}
+
+// sessionCar holds the state of one car while building a practice or
+// qualifying feed
+type sessionCar struct {
+	carNumber int
+	standing  int // place in the standings, the order of cars without a time
+	runs      []timedRun
+	failure   *EngineFailure
+	best      [len(segmentLengths)]float64 // best lap of each segment, or of practice in the first
+	qualified qualifyingCar
+	position  int
+}
+
+// lapCompletion is one lap crossing the line in a practice or qualifying feed
+type lapCompletion struct {
+	time    float64
+	car     *sessionCar
+	lap     int
+	lapTime float64
+	segment int
+}
+
+// practiceLapTimes draws the lap times the timing feed shows for the runs of
+// a car over practice, at its pace on fresh tires and its fuel load
+func practiceLapTimes(s *sampler, opts Options, c Competitor, driver Driver) []timedRun {
+	p, tires := opts.parameters(), opts.tireModel()
+	pace := c.LastLapTime / tires.lastLapFactor(c.TireCompound, driver, c.TireAge)
+	var runs []timedRun
+	for _, run := range practiceRuns(opts, c.CarNumber) {
+		timed := timedRun{start: run.start, kinds: run.laps, compound: run.compound}
+		fuelFactor := fuelEffect(p, p.CurrentFuel) / fuelEffect(p, run.fuel)
+		for i, kind := range run.laps {
+			tireFactor, _ := tires.lap(run.compound, driver, i, i+1)
+			lapTime := pace*tireFactor*fuelFactor/lapPace[kind] + s.normalRandom(0, driver.lapTimeSigma(pace))
+			if kind == LapRacing {
+				for range monacoCorners {
+					if s.float64() < driver.MistakeProbability {
+						lapTime += s.uniformRandom(0.5, 2.5) // Lock-up or missed apex
+					}
+				}
+			}
+			timed.lapTimes = append(timed.lapTimes, round3(lapTime))
+		}
+		runs = append(runs, timed)
+	}
+	return runs
+}
+
+// generateSessionFeed returns the timing feed of a practice or qualifying
+// session ordered by session time. Cars leave the garage, set lap times,
+// which practice adds to plans, and return to it, classified by their best
+// lap; in qualifying only the times of the current segment count, and the
+// slowest cars are eliminated at the end of the first two segments. Cars
+// whose engine fails retire to the garage but keep their times. The model
+// stops between cars when ctx is cancelled
+func generateSessionFeed(ctx context.Context, s *sampler, opts Options, competitors []Competitor, plans *sessionPlans, gt GroundTruth) ([]TimingMessage, error) {
+	qualifying := opts.session() == SessionQualifying
+	qualified := plans.qualified
+	soft := compoundNames(opts.compounds())[0]
+
+	cars := make([]*sessionCar, len(competitors))
+	for i, c := range competitors {
+		driver := competitorDriver(opts, c)
+		car := &sessionCar{carNumber: c.CarNumber, standing: c.Position, position: c.Position}
+		if qualifying {
+			car.qualified = qualified[i]
+			for _, run := range qualified[i].runs {
+				car.runs = append(car.runs, timedRun{segment: run.segment, start: run.start,
+					kinds: qualifyingLaps[:len(run.lapTimes)], lapTimes: run.lapTimes, compound: soft})
+			}
+		} else {
+			car.runs = practiceLapTimes(s, opts, c, driver)
+			lap := 0
+			for _, run := range car.runs {
+				for _, lapTime := range run.lapTimes {
+					lap++
+					plans.setLapTime(c.CarNumber, lap, lapTime)
+				}
+			}
+		}
+		for j, f := range gt.EngineFailures {
+			if f.CarNumber == c.CarNumber && f.Retired {
+				car.failure = &gt.EngineFailures[j]
+			}
+		}
+		cars[i] = car
+	}
+
+	// Track status at the start and end of each segment
+	var feed []TimingMessage
+	if qualifying {
+		for segment := 1; segment <= len(segmentLengths); segment++ {
+			start := segmentStartTime(segment)
+			feed = append(feed, TimingMessage{round3(start), MessageTrackStatus, TrackStatusData{"green", fmt.Sprintf("Q%d started", segment)}})
+			if segment == len(segmentLengths) {
+				break
+			}
+			end := round3(start + segmentLengths[segment-1])
+			feed = append(feed, TimingMessage{end, MessageTrackStatus, TrackStatusData{"chequered", fmt.Sprintf("Q%d ended", segment)}})
+			for _, car := range cars {
+				if car.qualified.eliminated == segment {
+					feed = append(feed, TimingMessage{end, MessageElimination, EliminationData{car.carNumber, segment, car.qualified.position}})
+				}
+			}
+		}
+	} else {
+		feed = append(feed, TimingMessage{0, MessageTrackStatus, TrackStatusData{"green", "Session started"}})
+	}
+
+	// Each car's runs from the garage, up to its engine failure
+	var completions []lapCompletion
+	for _, car := range cars {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		lap := 0
+	runs:
+		for _, run := range car.runs {
+			t := run.start
+			feed = append(feed, TimingMessage{round3(t), MessagePitOut, PitData{CarNumber: car.carNumber, Lap: lap, TireCompound: run.compound}})
+			for i, lapTime := range run.lapTimes {
+				lap++
+				if car.failure != nil && car.failure.RetireLap == lap {
+					feed = append(feed, TimingMessage{round3(t + lapTime*car.failure.RetireProgress), MessageRetirement, RetirementData{
+						CarNumber: car.carNumber,
+						Lap:       lap,
+						Reason:    car.failure.Mode,
+					}})
+					break runs
+				}
+				t += lapTime
+				completions = append(completions, lapCompletion{t, car, lap, lapTime, max(run.segment, 1)})
+				if run.kinds[i] == LapIn {
+					feed = append(feed, TimingMessage{round3(t), MessagePitIn, PitData{CarNumber: car.carNumber, Lap: lap, TireCompound: run.compound}})
+				}
+			}
+		}
+	}
+
+	// Classify every lap as it crosses the line
+	sort.SliceStable(completions, func(i, j int) bool { return completions[i].time < completions[j].time })
+	order := append([]*sessionCar(nil), cars...)
+	for _, c := range completions {
+		car, segment := c.car, c.segment
+		if best := car.best[segment-1]; best == 0 || c.lapTime < best {
+			car.best[segment-1] = c.lapTime
+		}
+		sort.SliceStable(order, func(i, j int) bool { return sessionAhead(order[i], order[j], segment) })
+
+		var position int
+		for i, other := range order {
+			if other == car {
+				position = i + 1
+			}
+			if other.position != i+1 {
+				feed = append(feed, TimingMessage{round3(c.time), MessagePositionChange, PositionChangeData{
+					CarNumber:        other.carNumber,
+					PreviousPosition: other.position,
+					Position:         i + 1,
+				}})
+				other.position = i + 1
+			}
+		}
+		feed = append(feed, TimingMessage{round3(c.time), MessageLapCompleted, LapCompletedData{
+			CarNumber: car.carNumber,
+			Lap:       c.lap,
+			LapTime:   c.lapTime,
+			Position:  position,
+		}})
+		// Cars with a time are only ever behind others with one
+		if best := car.best[segment-1]; best > 0 {
+			leader, ahead := order[0].best[segment-1], order[max(position-2, 0)].best[segment-1]
+			feed = append(feed, TimingMessage{round3(c.time), MessageGapUpdate, GapUpdateData{
+				CarNumber:   car.carNumber,
+				Position:    position,
+				GapToLeader: round3(best - leader),
+				Interval:    round3(best - ahead),
+			}})
+		}
+	}
+
+	sort.SliceStable(feed, func(i, j int) bool { return feed[i].SessionTime < feed[j].SessionTime })
+	feed = append(feed, TimingMessage{feed[len(feed)-1].SessionTime, MessageTrackStatus, TrackStatusData{"chequered", "Chequered flag"}})
+	return feed, nil
+}
+
+// sessionAhead reports whether car a is classified ahead of car b during
+// segment. Cars knocked out earlier are classified behind those still
+// running in their final order; the rest go by their best lap of the
+// segment, then of the segments before, then by the standings
+func sessionAhead(a, b *sessionCar, segment int) bool {
+	outA := a.qualified.eliminated != 0 && a.qualified.eliminated < segment
+	outB := b.qualified.eliminated != 0 && b.qualified.eliminated < segment
+	switch {
+	case outA != outB:
+		return outB
+	case outA:
+		return a.qualified.position < b.qualified.position
+	}
+	for g := segment; g >= 1; g-- {
+		if ta, tb := timeOrInf(a.best[g-1]), timeOrInf(b.best[g-1]); ta != tb {
+			return ta < tb
+		}
+	}
+	return a.standing < b.standing
+}
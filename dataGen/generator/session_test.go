@@ -0,0 +1,130 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+// TestSessionTypes checks that the telemetry of every session type runs
+// forward in time through the laps of the session plan, and that each feed
+// opens the way its session does
+func TestSessionTypes(t *testing.T) {
+	for _, session := range SessionTypes() {
+		t.Run(session, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.Laps = 12
+			opts.Session = session
+			opts.EngineFailures = []EngineFailure{}
+			result, err := Generate(context.Background(), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d, p := result.Telemetry, result.RaceParameters
+			plan := result.plans.carLaps(opts, opts.carNumber())
+			if len(plan) == 0 || len(plan) > opts.sessionLaps() {
+				t.Fatalf("%d planned laps for a %d lap session", len(plan), opts.sessionLaps())
+			}
+			if math.Abs(d.Time[0]-plan[0].start) > 1/d.SampleRate {
+				t.Errorf("telemetry starts at %v, want %v", d.Time[0], plan[0].start)
+			}
+			next := 0
+			for i := 0; i < d.Len(); i++ {
+				if i == 0 || d.Lap[i] != d.Lap[i-1] {
+					if next == len(plan) || d.Lap[i] != plan[next].lap {
+						t.Fatalf("telemetry lap %d at sample %d does not follow the session plan", d.Lap[i], i)
+					}
+					next++
+				}
+				if i > 0 && d.Time[i] <= d.Time[i-1] {
+					t.Fatalf("time %v at sample %d does not follow %v", d.Time[i], i, d.Time[i-1])
+				}
+				// Lap 1 of a race starts from the grid slot behind the line
+				lapStart := 0.0
+				if start := ourCar(opts, result.plans).start; start != nil && d.Lap[i] == 1 {
+					lapStart = -start.slotProgress(p.TrackLength)
+				}
+				if progress := d.Distance[i]/p.TrackLength - float64(d.Lap[i]-1); progress < lapStart-1e-6 || progress > 1+1e-9 {
+					t.Fatalf("lap %d progress %v at sample %d", d.Lap[i], progress, i)
+				}
+				if d.Speed[i] < 0 || d.Speed[i] > p.MaxSpeed {
+					t.Fatalf("speed %v at sample %d", d.Speed[i], i)
+				}
+			}
+			if next != len(plan) {
+				t.Errorf("telemetry covers %d of %d planned laps", next, len(plan))
+			}
+			for _, lap := range result.Laps {
+				if lap.LapType != plan[lap.Lap-plan[0].lap].kind {
+					t.Errorf("lap %d summarized as %s, planned %s", lap.Lap, lap.LapType, plan[lap.Lap-plan[0].lap].kind)
+				}
+			}
+
+			first := result.TimingFeed[0].Data.(TrackStatusData)
+			switch session {
+			case SessionRace:
+				if first.Status != "formation" || plan[0].kind != LapFormation || d.Speed[0] != 0 {
+					t.Errorf("race opens with %+v and a %s lap at %v km/h", first, plan[0].kind, d.Speed[0])
+				}
+			case SessionPractice, SessionQualifying:
+				if len(result.GroundTruth.PitStops) != 0 {
+					t.Errorf("%d pit stops in %s", len(result.GroundTruth.PitStops), session)
+				}
+			}
+		})
+	}
+}
+
+// TestQualifyingEliminations checks that the slowest quarter of the field
+// is knocked out after each of the first two segments, and that no car sets
+// a lap once eliminated
+func TestQualifyingEliminations(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Laps = 15
+	opts.Session = SessionQualifying
+	result, err := GenerateSession(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eliminated := make(map[int]float64) // car number -> time knocked out
+	positions := make(map[int]bool)
+	segments := [3]int{}
+	for _, msg := range result.TimingFeed {
+		switch data := msg.Data.(type) {
+		case EliminationData:
+			eliminated[data.CarNumber] = msg.SessionTime
+			positions[data.Position] = true
+			segments[data.Segment]++
+		case LapCompletedData:
+			if _, ok := eliminated[data.CarNumber]; ok {
+				t.Errorf("car %d completes lap %d after elimination", data.CarNumber, data.Lap)
+			}
+		}
+	}
+	out := opts.gridSize() / 4
+	if segments[1] != out || segments[2] != out {
+		t.Fatalf("%d and %d cars eliminated in Q1 and Q2, want %d each", segments[1], segments[2], out)
+	}
+	for position := opts.gridSize() - 2*out + 1; position <= opts.gridSize(); position++ {
+		if !positions[position] {
+			t.Errorf("no eliminated car classified P%d", position)
+		}
+	}
+}
+
+// TestGenerateSessionCancelled checks that every session type's timing
+// model stops when the context is cancelled
+func TestGenerateSessionCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, session := range SessionTypes() {
+		opts := DefaultOptions()
+		opts.Session = session
+		if _, err := GenerateSession(ctx, opts); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: error %v, want %v", session, err, context.Canceled)
+		}
+	}
+}
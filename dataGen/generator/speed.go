@@ -0,0 +1,69 @@
+package generator
+
+import "math"
+
+// standardGravity converts accelerations between g and m/s²
+const standardGravity = 9.81
+
+// accelerationLimit returns the largest acceleration in m/s² at speed km/h.
+// Traction limits low speeds and drag erodes the limit towards maxSpeed
+func (c *Calibration) accelerationLimit(speed, maxSpeed float64) float64 {
+	ratio := math.Min(speed/maxSpeed, 1)
+	return c.MaxAccel * standardGravity * (1 - ratio*ratio)
+}
+
+// brakingLimit returns the largest deceleration in m/s² at speed km/h.
+// Downforce increases braking grip with the square of speed
+func (c *Calibration) brakingLimit(speed, maxSpeed float64) float64 {
+	ratio := math.Min(speed/maxSpeed, 1)
+	return c.MaxBraking * standardGravity * (1 + 0.5*ratio*ratio)
+}
+
+// solveSpeedTrace turns a target speed profile (km/h, one value per sample,
+// dt seconds apart) into a trace the car can actually drive: a forward pass
+// caps acceleration out of corners and a backward pass brings braking
+// forward so corner targets are met without exceeding the braking limit.
+// When loop is set the lap is treated as a loop so the trace is continuous
+// across the line; laps through the pit lane start and end elsewhere and are
+// solved open. When entry is positive the lap also accelerates or brakes
+// from that speed, the last sample of the previous lap
+func (c *Calibration) solveSpeedTrace(target []float64, dt, maxSpeed, entry float64, loop bool) []float64 {
+	n := len(target)
+	speed := append([]float64(nil), target...)
+	if n < 2 {
+		return speed
+	}
+	const kmh = 3.6 // km/h per m/s
+
+	// Two laps around the loop let each pass settle across the line
+	passes := 1
+	if loop {
+		passes = 2
+	}
+	for i := 1; i < passes*n; i++ {
+		cur, prev := i%n, (i-1)%n
+		limit := speed[prev] + c.accelerationLimit(speed[prev], maxSpeed)*dt*kmh
+		speed[cur] = math.Min(speed[cur], limit)
+	}
+	for i := passes*n - 2; i >= 0; i-- {
+		cur, next := i%n, (i+1)%n
+		limit := speed[next] + c.brakingLimit(speed[next], maxSpeed)*dt*kmh
+		speed[cur] = math.Min(speed[cur], limit)
+	}
+
+	// Join the previous lap until the trace is within reach of its last speed
+	prev := entry
+	for i := 0; entry > 0 && i < n; i++ {
+		up := prev + c.accelerationLimit(prev, maxSpeed)*dt*kmh
+		down := prev - c.brakingLimit(prev, maxSpeed)*dt*kmh
+		if speed[i] > up {
+			speed[i] = up
+		} else if speed[i] < down {
+			speed[i] = down
+		} else {
+			break
+		}
+		prev = speed[i]
+	}
+	return speed
+}
@@ -0,0 +1,42 @@
+package generator
+
+import (
+	"math"
+	"testing"
+)
+
+// TestSpeedTraceLimits checks that our car's planned speed never changes
+// between consecutive samples faster than it can accelerate or brake,
+// across lap boundaries and the pit lane included, at high sample rates
+func TestSpeedTraceLimits(t *testing.T) {
+	const kmh = 3.6 // km/h per m/s
+	for _, rate := range []float64{100, 1000} {
+		for seed := int64(1); seed <= 5; seed++ {
+			opts := DefaultOptions()
+			opts.Seed = seed
+			opts.Laps = 5
+			opts.SampleRate = rate
+			opts.PitLaps = []int{2}
+			opts.EngineFailures = []EngineFailure{}
+			model := newTelemetryModel(opts, ourCar(opts, nil))
+			planner := model.newPlanner(newSampler(seed))
+			cal, maxSpeed := model.cal, model.params.MaxSpeed
+
+			prev := math.NaN()
+			for lap := 1; lap <= opts.Laps; lap++ {
+				for i, speed := range planner.next().speeds {
+					if !math.IsNaN(prev) {
+						accel := (speed - prev) / kmh * rate
+						if limit := cal.accelerationLimit(prev, maxSpeed); accel > limit*(1+1e-9) {
+							t.Fatalf("%g Hz seed %d lap %d sample %d: %.1f to %.1f km/h accelerates at %.1f m/s², limit %.1f", rate, seed, lap, i, prev, speed, accel, limit)
+						}
+						if limit := cal.brakingLimit(math.Max(prev, speed), maxSpeed); -accel > limit*(1+1e-9) {
+							t.Fatalf("%g Hz seed %d lap %d sample %d: %.1f to %.1f km/h brakes at %.1f m/s², limit %.1f", rate, seed, lap, i, prev, speed, -accel, limit)
+						}
+					}
+					prev = speed
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package generator
+
+import "math"
+
+// RaceStart is how a car got away from its grid slot at the start of a
+// race, recorded in the ground truth
+type RaceStart struct {
+	CarNumber    int     `json:"car_number"`
+	GridSlot     int     `json:"grid_slot"`
+	LightsOut    float64 `json:"lights_out"`    // session time the race starts
+	ReactionTime float64 `json:"reaction_time"` // s from lights out to the clutch biting
+	LaunchLoss   float64 `json:"launch_loss"`   // s lost getting the car moving
+	Wheelspin    bool    `json:"wheelspin"`     // the launch lost time to wheelspin rather than bogging down
+	Incident     bool    `json:"incident"`      // contact in the first corner
+	IncidentLoss float64 `json:"incident_loss"` // s lost recovering from the contact
+}
+
+// Race start model
+const (
+	startStream       = -6   // random stream the start is drawn from
+	gridSlotSpacing   = 8    // m between grid slots, staggered left and right
+	gridStagger       = 0.25 // s a grid slot costs at the line
+	standingStartLoss = 2    // s a standing start costs against a flying lap
+	launchRPM         = 11000
+	launchFlare       = 1500 // rpm the engine flares to with wheelspin
+	launchBog         = 3500 // rpm the engine drops by when it bogs down
+	launchSpin        = 30   // km/h the rear wheels spin up to with wheelspin
+	clutchRelease     = 0.6  // s to let the clutch out
+	launchLossSigma   = 0.25 // s
+	recoveryPace      = 0.35 // fraction of racing speed while recovering from contact
+)
+
+// Race start ranges
+var (
+	lightsHold       = Range{4, 7}       // s from the field forming up to lights out
+	reactionTime     = Range{0.15, 0.35} // s
+	firstCornerRisk  = Range{0.02, 0.1}  // chance of contact from the front to the back of the grid
+	incidentLoss     = Range{2, 10}      // s
+	firstCornerStart = monacoCorners[0].Apex - 0.03
+	firstCornerEnd   = monacoCorners[0].Apex + 0.02
+)
+
+// lightsOut returns the session time the lights go out, the same for every
+// car, after the formation lap and the field forming up on the grid
+func lightsOut(opts Options) float64 {
+	s := newSampler(deriveSeed(opts.Seed, startStream))
+	return round3(formationLapTime(opts) + s.uniformRandom(lightsHold.Min, lightsHold.Max))
+}
+
+// planStart draws the start of car from gridSlot from its own stream, or
+// returns nil outside a race. Cars further back run a higher risk of contact
+// in the first corner
+func planStart(opts Options, carNumber, gridSlot int, driver Driver) *RaceStart {
+	if opts.session() != SessionRace {
+		return nil
+	}
+	s := newSampler(deriveSeed(opts.Seed, carNumber, startStream))
+	start := &RaceStart{
+		CarNumber:    carNumber,
+		GridSlot:     gridSlot,
+		LightsOut:    lightsOut(opts),
+		ReactionTime: round3(s.uniformRandom(reactionTime.Min, reactionTime.Max)),
+		LaunchLoss:   round3(math.Abs(s.normalRandom(0, launchLossSigma))),
+	}
+	start.Wheelspin = s.float64() < 0.5
+
+	back := float64(gridSlot-1) / float64(max(opts.gridSize()-1, 1))
+	risk := firstCornerRisk.Min + (firstCornerRisk.Max-firstCornerRisk.Min)*back
+	risk *= 1 + driver.MistakeProbability*10
+	if s.float64() < risk {
+		start.Incident = true
+		start.IncidentLoss = round3(s.uniformRandom(incidentLoss.Min, incidentLoss.Max))
+	}
+	return start
+}
+
+// slotProgress returns the lap progress the grid slot lies behind the line
+func (r *RaceStart) slotProgress(trackLength float64) float64 {
+	return float64(r.GridSlot-1) * gridSlotSpacing / (trackLength * 1000)
+}
+
+// lapOneLoss returns the seconds the start costs on lap 1 against a flying
+// lap, from the grid slot to the line
+func (r *RaceStart) lapOneLoss() float64 {
+	return standingStartLoss + gridStagger*float64(r.GridSlot-1) + r.ReactionTime + r.LaunchLoss + r.IncidentLoss
+}
+
+// startLayout returns the lap progress and pit phase of every sample of lap
+// 1 of a race: stationary in the grid slot until the car gets moving after
+// lights out, then racing from the slot at lapSamples a lap, crawling
+// through the first corner after contact, held up in traffic for held extra
+// samples from heldStart, and into the pit lane for stop when pitIn
+func (m *telemetryModel) startLayout(start *RaceStart, lapSamples, held int, stop PitStop, pitIn bool) ([]float64, []pitPhase) {
+	var progress []float64
+	var phase []pitPhase
+	slot := -start.slotProgress(m.params.TrackLength)
+	n := float64(lapSamples)
+	for i := 0; i < int(math.Round((start.ReactionTime+start.LaunchLoss)*m.sampleRate)); i++ {
+		progress = append(progress, slot)
+		phase = append(phase, onGrid)
+	}
+	track := func(from, to float64, samples float64, p pitPhase) {
+		for i := 0; i < int(math.Round(samples)); i++ {
+			progress = append(progress, from+(to-from)*float64(i)/samples)
+			phase = append(phase, p)
+		}
+	}
+	track(slot, firstCornerStart, (firstCornerStart-slot)*n, onTrack)
+	corner := (firstCornerEnd - firstCornerStart) * n
+	if start.Incident {
+		track(firstCornerStart, firstCornerEnd, corner+start.IncidentLoss*m.sampleRate, recovering)
+	} else {
+		track(firstCornerStart, firstCornerEnd, corner, onTrack)
+	}
+	end := 1.0
+	if pitIn {
+		end = m.pitLane.Entry
+	}
+	split := end
+	if held > 0 {
+		split = math.Max(firstCornerEnd, m.heldStart(pitIn))
+	}
+	track(firstCornerEnd, split, (split-firstCornerEnd)*n, onTrack)
+	track(split, end, (end-split)*n+float64(held), onTrack)
+	if !pitIn {
+		return progress, phase
+	}
+	return m.pitInLayout(progress, phase, stop, false)
+}
+
+// formUp extends the formation lap to stop in the grid slot, holding there
+// until lights out at the session time lightsOut
+func (m *telemetryModel) formUp(progress []float64, phase []pitPhase, startTime, lightsOut float64) ([]float64, []pitPhase) {
+	end := 1 - m.car.start.slotProgress(m.params.TrackLength)
+	for i := range progress {
+		progress[i] *= end
+	}
+	hold := int(math.Round((lightsOut-startTime)*m.sampleRate)) - len(progress)
+	for i := 0; i < hold; i++ {
+		progress = append(progress, end)
+		phase = append(phase, onGrid)
+	}
+	return progress, phase
+}
+
+// launchState is the launch channels of a sample on the grid or pulling away
+type launchState struct {
+	clutch float64 // % of clutch travel, 100 pulled in
+	rpm    float64 // engine speed held by the driver, zero once the launch is over
+	spin   float64 // km/h the rear wheels spin faster than the car moves
+}
+
+// launchAt returns the launch channels at sample of plan: the clutch pulled
+// and the revs held in the grid slot, then the clutch let out after the
+// driver reacts to lights out, with the engine flaring on wheelspin or
+// bogging down
+func (m *telemetryModel) launchAt(plan *lapPlan, sample int) launchState {
+	start := m.car.start
+	switch {
+	case start == nil:
+		return launchState{}
+	case plan.lap == 0 && plan.phaseAt(sample) == onGrid:
+		return launchState{clutch: 100, rpm: launchRPM}
+	case plan.lap != 1 || sample >= plan.launchEnd:
+		return launchState{}
+	}
+	bite := int(math.Round(start.ReactionTime * m.sampleRate))
+	if sample < bite {
+		return launchState{clutch: 100, rpm: launchRPM}
+	}
+	t := float64(sample-bite) / float64(max(plan.launchEnd-bite, 1))
+	if start.Wheelspin {
+		return launchState{clutch: 100 * (1 - t), rpm: launchRPM + launchFlare*math.Sin(math.Pi*t), spin: launchSpin * (1 - t)}
+	}
+	return launchState{clutch: 100 * (1 - t), rpm: launchRPM - launchBog*math.Sin(math.Pi*t)}
+}
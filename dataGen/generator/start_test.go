@@ -0,0 +1,135 @@
+package generator
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// TestRaceStart checks that our car waits in its grid slot with the clutch
+// in until the driver reacts to lights out, that every car's start is in the
+// ground truth and that the feed starts the race at lights out
+func TestRaceStart(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Laps = 2
+	opts.Session = SessionRace
+	opts.EngineFailures = []EngineFailure{}
+	result, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	starts := result.GroundTruth.Starts
+	if len(starts) != len(result.Competitors) {
+		t.Fatalf("%d starts in the ground truth, want %d", len(starts), len(result.Competitors))
+	}
+	var ours RaceStart
+	for i, start := range starts {
+		if start.GridSlot != result.Competitors[i].Position || start.LightsOut != starts[0].LightsOut {
+			t.Errorf("start %+v for %+v", start, result.Competitors[i])
+		}
+		if start.CarNumber == opts.carNumber() {
+			ours = start
+		}
+	}
+
+	d := result.Telemetry
+	dt := 1 / d.SampleRate
+	moving := ours.LightsOut + ours.ReactionTime + ours.LaunchLoss
+	for i := 0; i < d.Len(); i++ {
+		switch {
+		case d.Lap[i] == 0 && d.Clutch[i] > 0 && d.Speed[i] != 0:
+			t.Fatalf("car moving at %v km/h with the clutch in at %v", d.Speed[i], d.Time[i])
+		case d.Lap[i] == 1 && d.Time[i] < moving-dt && d.Speed[i] != 0:
+			t.Fatalf("car moving at %v km/h at %v before getting away at %v", d.Speed[i], d.Time[i], moving)
+		case d.Lap[i] == 1 && d.Time[i] > moving+clutchRelease+dt && d.Clutch[i] != 0:
+			t.Fatalf("clutch at %v%% at %v after the launch", d.Clutch[i], d.Time[i])
+		case d.Lap[i] == 1 && d.Lap[i-1] == 0 && math.Abs(d.Time[i]-ours.LightsOut) > dt:
+			t.Errorf("lap 1 starts at %v, lights out at %v", d.Time[i], ours.LightsOut)
+		}
+	}
+
+	var green bool
+	for _, msg := range result.TimingFeed {
+		if status, ok := msg.Data.(TrackStatusData); ok && status.Status == "green" && msg.SessionTime == ours.LightsOut {
+			green = true
+		}
+		if lap, ok := msg.Data.(LapCompletedData); ok && msg.SessionTime <= ours.LightsOut {
+			t.Errorf("car %d completes lap %d before lights out", lap.CarNumber, lap.Lap)
+		}
+	}
+	if !green {
+		t.Errorf("no green flag at lights out %v", ours.LightsOut)
+	}
+}
+
+// TestFirstCornerContact checks that contact at the start damages the car
+// in the first corner and slows it through the corner
+func TestFirstCornerContact(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Laps = 1
+	opts.Session = SessionRace
+	opts.GridPosition = opts.gridSize()
+	opts.EngineFailures = []EngineFailure{}
+	for opts.Seed = 1; !ourCar(opts, nil).start.Incident; opts.Seed++ {
+	}
+	result, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var contact bool
+	for _, e := range result.GroundTruth.Damage {
+		contact = contact || e.CarNumber == opts.carNumber() && e.Cause == DamageContact && e.Lap == 1
+	}
+	if !contact {
+		t.Errorf("seed %d: no contact damage for our car in %+v", opts.Seed, result.GroundTruth.Damage)
+	}
+
+	d, p := result.Telemetry, result.RaceParameters
+	var corner int
+	var cornerSpeed float64
+	for i := 0; i < d.Len(); i++ {
+		progress := d.Distance[i] / p.TrackLength
+		if d.Lap[i] == 1 && progress >= firstCornerStart && progress < firstCornerEnd {
+			corner++
+			cornerSpeed += d.Speed[i]
+		}
+	}
+	start := result.GroundTruth.Starts[len(result.GroundTruth.Starts)-1]
+	want := int((firstCornerEnd-firstCornerStart)*p.ReferenceLapTime*d.SampleRate + start.IncidentLoss*d.SampleRate)
+	if start.CarNumber != opts.carNumber() || corner < want-1 || cornerSpeed/float64(corner) > 60 {
+		t.Errorf("seed %d: %d samples through the first corner at %.0f km/h, want a slow crawl", opts.Seed, corner, cornerSpeed/float64(corner))
+	}
+}
+
+// TestLapOnePitStop checks that a stop at the end of lap 1 takes our car
+// from the grid into the pit lane, under the limiter from the pit entry on
+func TestLapOnePitStop(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Laps = 2
+	opts.Session = SessionRace
+	opts.PitLaps = []int{1}
+	opts.EngineFailures = []EngineFailure{}
+	result, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, p := result.Telemetry, result.RaceParameters
+	lane := opts.pitLane()
+	var limiter int
+	for i := 0; i < d.Len(); i++ {
+		progress := d.Distance[i]/p.TrackLength - float64(d.Lap[i]-1)
+		if d.Lap[i] != 1 || progress < lane.Entry {
+			continue
+		}
+		if d.PitLimiter[i] == 0 || d.Speed[i] > lane.SpeedLimit {
+			t.Fatalf("%v km/h with the limiter at %d at lap 1 progress %.3f, past the pit entry", d.Speed[i], d.PitLimiter[i], progress)
+		}
+		limiter++
+	}
+	if limiter == 0 {
+		t.Error("our car never enters the pit lane on lap 1")
+	}
+}
@@ -0,0 +1,101 @@
+package generator
+
+import "math"
+
+// Steering geometry and handling constants
+const (
+	wheelbase          = 3.6  // meters
+	steeringRatio      = 11.0 // steering wheel to road wheel angle
+	baseUndersteer     = 0.8  // degrees of road wheel per g of lateral acceleration
+	tempBalanceGain    = 0.05 // understeer per °C the fronts run hotter than the rears
+	wearUndersteerGain = 8.0  // understeer per unit of tire lap time loss
+	steeringNoise      = 1.5  // degrees at the wheel, stationary driver correction noise
+	steeringNoiseTau   = 0.3  // seconds, correction noise correlation time
+	tireBalanceTau     = 2.0  // seconds, time constant of the tire balance average
+	maxLateralG        = 4.5  // grip limit, the car slides wide beyond it
+	maxSteeringRate    = 1500 // degrees per second the driver can turn the wheel
+)
+
+// steeringModel turns track curvature into a steering wheel angle in degrees,
+// positive to the left. The driver follows the corner's geometric angle,
+// adds understeer or oversteer correction depending on tire state and makes
+// small correlated corrections, never turning the wheel faster than
+// maxSteeringRate
+type steeringModel struct {
+	noise       *ouProcess
+	smoothing   float64 // weight of the newest sample in the tire balance average
+	maxStep     float64 // degrees the wheel can turn between samples
+	tempBalance float64 // smoothed front minus rear tire temperature
+	last        float64 // wheel angle of the previous sample
+	initialized bool
+}
+
+// newSteeringModel creates a steering model sampled every dt seconds
+func newSteeringModel(dt float64) *steeringModel {
+	return &steeringModel{
+		noise:     newOUProcess(steeringNoise, steeringNoiseTau, dt),
+		smoothing: 1 - math.Exp(-dt/tireBalanceTau),
+		maxStep:   maxSteeringRate * dt,
+	}
+}
+
+// start draws the driver's correction at the first sample, so a model
+// created mid-session picks up as if it had been running all along
+func (m *steeringModel) start(s *sampler) {
+	m.noise.start(s)
+}
+
+// steeringAngles returns the steering wheel angle at every sample of plan,
+// carrying on from where steering left the lap before. The tire balance
+// follows the temperatures each axle runs at around the inputs of each
+// sample's zone
+func (m *telemetryModel) steeringAngles(s *sampler, steering *steeringModel, plan *lapPlan) []float64 {
+	tt := m.cal.TireTemps
+	stintLap := float64(plan.stintLap)
+	baseTemp := m.params.TrackTemp + m.car.driver.tireTempOffset() + plan.tireTemp
+	axleTemp := func(left, right TireTempModel, throttle, brake float64) float64 {
+		l := clamp(left.mean(baseTemp+left.Offset+stintLap*left.PerLap, throttle, brake), 80, 140)
+		r := clamp(right.mean(baseTemp+right.Offset+stintLap*right.PerLap, throttle, brake), 80, 140)
+		return (l + r) / 2
+	}
+
+	out := make([]float64, len(plan.speeds))
+	for i, speed := range plan.speeds {
+		inputs := m.cal.Inputs.zone(zoneOf(plan.progressAt(i, m.samplesPerLap), speed))
+		throttle := (inputs.Throttle.Min + inputs.Throttle.Max) / 2
+		brake := (inputs.Brake.Min + inputs.Brake.Max) / 2
+		frontTemp := axleTemp(tt.FL, tt.FR, throttle, brake)
+		rearTemp := axleTemp(tt.RL, tt.RR, throttle, brake)
+		out[i] = steering.angle(s, plan.curvatures[i], speed, frontTemp, rearTemp, plan.tireDeg)
+	}
+	return out
+}
+
+// angle returns the steering wheel angle for one sample. kappa is the track
+// curvature in 1/m, speed in km/h, frontTemp and rearTemp the average tire
+// temperatures of each axle and tireDeg the tire lap time factor
+func (m *steeringModel) angle(s *sampler, kappa, speed, frontTemp, rearTemp, tireDeg float64) float64 {
+	balance := frontTemp - rearTemp
+	first := !m.initialized
+	if first {
+		m.tempBalance = balance
+		m.initialized = true
+	}
+	m.tempBalance += m.smoothing * (balance - m.tempBalance)
+
+	// Understeer gradient: hot or worn fronts push, hot rears rotate the car
+	understeer := baseUndersteer + tempBalanceGain*m.tempBalance + wearUndersteerGain*(tireDeg-1)
+
+	v := speed / 3.6
+	lateralG := clamp(v*v*kappa/standardGravity, -maxLateralG, maxLateralG)
+	roadWheel := wheelbase*kappa*180/math.Pi + understeer*lateralG
+
+	// Corrections grow with cornering load
+	correction := m.noise.next(s) * (1 + math.Abs(lateralG))
+	angle := roadWheel*steeringRatio + correction
+	if !first {
+		angle = clamp(angle, m.last-m.maxStep, m.last+m.maxStep)
+	}
+	m.last = angle
+	return angle
+}
@@ -0,0 +1,68 @@
+package generator
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// TestSteeringFollowsCorners checks that at each apex the wheel is turned
+// the way the corner goes, and further in tighter corners
+func TestSteeringFollowsCorners(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Laps = 3
+	opts.EngineFailures = []EngineFailure{}
+	result, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mean wheel angle at the sample nearest each apex over the laps
+	d, p := result.Telemetry, result.RaceParameters
+	apexAngles := make([]float64, len(monacoCorners))
+	for k, c := range monacoCorners {
+		for lap := 1; lap <= opts.Laps; lap++ {
+			nearest, best := -1, math.Inf(1)
+			for i := 0; i < d.Len(); i++ {
+				progress := d.Distance[i]/p.TrackLength - float64(d.Lap[i]-1)
+				if d.Lap[i] == lap && math.Abs(progress-c.Apex) < best {
+					nearest, best = i, math.Abs(progress-c.Apex)
+				}
+			}
+			apexAngles[k] += d.SteeringAngle[nearest] / float64(opts.Laps)
+		}
+		if apexAngles[k]*c.Direction <= 0 {
+			t.Errorf("%s: wheel at %.1f° at the apex, want turned towards %+g", c.Name, apexAngles[k], c.Direction)
+		}
+	}
+
+	for i, a := range monacoCorners {
+		for j, b := range monacoCorners {
+			if b.Radius >= 2*a.Radius && math.Abs(apexAngles[i]) <= math.Abs(apexAngles[j]) {
+				t.Errorf("%s (%gm) at %.1f° is turned no further than %s (%gm) at %.1f°", a.Name, a.Radius, apexAngles[i], b.Name, b.Radius, apexAngles[j])
+			}
+		}
+	}
+}
+
+// TestSteeringRate checks that the driver never turns the wheel faster than
+// maxSteeringRate, across the line as well as within a lap, whatever the
+// sample rate
+func TestSteeringRate(t *testing.T) {
+	for _, rate := range []float64{10, 100, 1000} {
+		opts := DefaultOptions()
+		opts.Laps = 2
+		opts.SampleRate = rate
+		result, err := Generate(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := result.Telemetry
+		maxStep := maxSteeringRate/rate + 0.1 // the channel is rounded to 0.1°
+		for i := 1; i < d.Len(); i++ {
+			if step := math.Abs(d.SteeringAngle[i] - d.SteeringAngle[i-1]); step > maxStep+1e-9 {
+				t.Fatalf("%g Hz: wheel turns %.1f° in a sample at %v, at most %.2f°", rate, step, d.Time[i], maxStep)
+			}
+		}
+	}
+}
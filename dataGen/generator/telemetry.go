@@ -0,0 +1,850 @@
+package generator
+
+import "math"
+
+// TelemetryData holds all telemetry channels
+type TelemetryData struct {
+	SampleRate float64 // samples per second, zero when unknown
+
+	Time              []float64
+	Lap               []int
+	Distance          []float64
+	Speed             []float64
+	Throttle          []float64
+	BrakePressure     []float64
+	TireTempFL        []float64
+	TireTempFR        []float64
+	TireTempRL        []float64
+	TireTempRR        []float64
+	FuelFlow          []float64
+	EngineRPM         []int
+	DRSActive         []int
+	BatteryDeployment []float64
+	Gear              []int
+	SteeringAngle     []float64
+	PitLimiter        []int
+	AeroDamage        []float64
+	BrakeTempFL       []float64
+	BrakeTempFR       []float64
+	BrakeTempRL       []float64
+	BrakeTempRR       []float64
+	BrakeBias         []float64
+	WheelSpeedFL      []float64
+	WheelSpeedFR      []float64
+	WheelSpeedRL      []float64
+	WheelSpeedRR      []float64
+	SlipFL            []float64 // longitudinal slip ratio in percent
+	SlipFR            []float64
+	SlipRL            []float64
+	SlipRR            []float64
+	SlipAngleFront    []float64 // degrees
+	SlipAngleRear     []float64
+	OilTemp           []float64 // °C
+	WaterTemp         []float64 // °C
+	OilPressure       []float64 // bar
+	TurboSpeed        []int     // MGU-H and turbo shaft speed in rpm
+	Clutch            []float64 // % of clutch travel, pulled in for the race start
+}
+
+// newTelemetryData allocates an empty TelemetryData with room for capacity samples
+func newTelemetryData(capacity int) *TelemetryData {
+	return &TelemetryData{
+		Time:              make([]float64, 0, capacity),
+		Lap:               make([]int, 0, capacity),
+		Distance:          make([]float64, 0, capacity),
+		Speed:             make([]float64, 0, capacity),
+		Throttle:          make([]float64, 0, capacity),
+		BrakePressure:     make([]float64, 0, capacity),
+		TireTempFL:        make([]float64, 0, capacity),
+		TireTempFR:        make([]float64, 0, capacity),
+		TireTempRL:        make([]float64, 0, capacity),
+		TireTempRR:        make([]float64, 0, capacity),
+		FuelFlow:          make([]float64, 0, capacity),
+		EngineRPM:         make([]int, 0, capacity),
+		DRSActive:         make([]int, 0, capacity),
+		BatteryDeployment: make([]float64, 0, capacity),
+		Gear:              make([]int, 0, capacity),
+		SteeringAngle:     make([]float64, 0, capacity),
+		PitLimiter:        make([]int, 0, capacity),
+		AeroDamage:        make([]float64, 0, capacity),
+		BrakeTempFL:       make([]float64, 0, capacity),
+		BrakeTempFR:       make([]float64, 0, capacity),
+		BrakeTempRL:       make([]float64, 0, capacity),
+		BrakeTempRR:       make([]float64, 0, capacity),
+		BrakeBias:         make([]float64, 0, capacity),
+		WheelSpeedFL:      make([]float64, 0, capacity),
+		WheelSpeedFR:      make([]float64, 0, capacity),
+		WheelSpeedRL:      make([]float64, 0, capacity),
+		WheelSpeedRR:      make([]float64, 0, capacity),
+		SlipFL:            make([]float64, 0, capacity),
+		SlipFR:            make([]float64, 0, capacity),
+		SlipRL:            make([]float64, 0, capacity),
+		SlipRR:            make([]float64, 0, capacity),
+		SlipAngleFront:    make([]float64, 0, capacity),
+		SlipAngleRear:     make([]float64, 0, capacity),
+		OilTemp:           make([]float64, 0, capacity),
+		WaterTemp:         make([]float64, 0, capacity),
+		OilPressure:       make([]float64, 0, capacity),
+		TurboSpeed:        make([]int, 0, capacity),
+		Clutch:            make([]float64, 0, capacity),
+	}
+}
+
+// Len returns the number of samples
+func (d *TelemetryData) Len() int {
+	return len(d.Time)
+}
+
+// Append adds all samples of other to the end of d
+func (d *TelemetryData) Append(other *TelemetryData) {
+	if d.SampleRate == 0 {
+		d.SampleRate = other.SampleRate
+	}
+	d.Time = append(d.Time, other.Time...)
+	d.Lap = append(d.Lap, other.Lap...)
+	d.Distance = append(d.Distance, other.Distance...)
+	d.Speed = append(d.Speed, other.Speed...)
+	d.Throttle = append(d.Throttle, other.Throttle...)
+	d.BrakePressure = append(d.BrakePressure, other.BrakePressure...)
+	d.TireTempFL = append(d.TireTempFL, other.TireTempFL...)
+	d.TireTempFR = append(d.TireTempFR, other.TireTempFR...)
+	d.TireTempRL = append(d.TireTempRL, other.TireTempRL...)
+	d.TireTempRR = append(d.TireTempRR, other.TireTempRR...)
+	d.FuelFlow = append(d.FuelFlow, other.FuelFlow...)
+	d.EngineRPM = append(d.EngineRPM, other.EngineRPM...)
+	d.DRSActive = append(d.DRSActive, other.DRSActive...)
+	d.BatteryDeployment = append(d.BatteryDeployment, other.BatteryDeployment...)
+	d.Gear = append(d.Gear, other.Gear...)
+	d.SteeringAngle = append(d.SteeringAngle, other.SteeringAngle...)
+	d.PitLimiter = append(d.PitLimiter, other.PitLimiter...)
+	d.AeroDamage = append(d.AeroDamage, other.AeroDamage...)
+	d.BrakeTempFL = append(d.BrakeTempFL, other.BrakeTempFL...)
+	d.BrakeTempFR = append(d.BrakeTempFR, other.BrakeTempFR...)
+	d.BrakeTempRL = append(d.BrakeTempRL, other.BrakeTempRL...)
+	d.BrakeTempRR = append(d.BrakeTempRR, other.BrakeTempRR...)
+	d.BrakeBias = append(d.BrakeBias, other.BrakeBias...)
+	d.WheelSpeedFL = append(d.WheelSpeedFL, other.WheelSpeedFL...)
+	d.WheelSpeedFR = append(d.WheelSpeedFR, other.WheelSpeedFR...)
+	d.WheelSpeedRL = append(d.WheelSpeedRL, other.WheelSpeedRL...)
+	d.WheelSpeedRR = append(d.WheelSpeedRR, other.WheelSpeedRR...)
+	d.SlipFL = append(d.SlipFL, other.SlipFL...)
+	d.SlipFR = append(d.SlipFR, other.SlipFR...)
+	d.SlipRL = append(d.SlipRL, other.SlipRL...)
+	d.SlipRR = append(d.SlipRR, other.SlipRR...)
+	d.SlipAngleFront = append(d.SlipAngleFront, other.SlipAngleFront...)
+	d.SlipAngleRear = append(d.SlipAngleRear, other.SlipAngleRear...)
+	d.OilTemp = append(d.OilTemp, other.OilTemp...)
+	d.WaterTemp = append(d.WaterTemp, other.WaterTemp...)
+	d.OilPressure = append(d.OilPressure, other.OilPressure...)
+	d.TurboSpeed = append(d.TurboSpeed, other.TurboSpeed...)
+	d.Clutch = append(d.Clutch, other.Clutch...)
+}
+
+// fuelEffect returns the speed multiplier of carrying fuelLoad kg of fuel
+func fuelEffect(p *RaceParameters, fuelLoad float64) float64 {
+	return 1.0 - (fuelLoad-20.0)*p.WeightPenalty // Weight penalty
+}
+
+// engineRPM returns the engine speed expected at speed km/h
+func engineRPM(speed float64) float64 {
+	switch {
+	case speed < 60:
+		return 7000 + speed*35
+	case speed < 120:
+		return 9000 + (speed-60)*25
+	default:
+		return 10500 + (speed-120)*15
+	}
+}
+
+// carSetup describes the car whose telemetry is being generated
+type carSetup struct {
+	number   int
+	driver   Driver
+	pace     float64 // speed multiplier relative to the reference lap
+	fuel     float64 // fuel load at the start of lap 1 in kg
+	tireAge  int     // laps already on the tires at the start of lap 1
+	compound string  // compound fitted at the start of lap 1
+	stops    []PitStop
+	damage   []DamageEvent
+	failure  *EngineFailure // nil for a reliable engine
+	laps     []sessionLap
+	start    *RaceStart // nil outside a race
+	traffic  []TrafficDelay
+}
+
+// ourCar returns the setup of our car, with its laps from plans
+func ourCar(opts Options, plans *sessionPlans) carSetup {
+	p := opts.parameters()
+	start := planStart(opts, opts.carNumber(), opts.gridPosition(), opts.driver())
+	return carSetup{
+		number:   opts.carNumber(),
+		driver:   opts.driver(),
+		pace:     1,
+		fuel:     p.CurrentFuel,
+		compound: p.TireCompound,
+		stops:    planPitStops(opts, opts.carNumber(), opts.ourPitLaps(), opts.PitCompounds, p.TireCompound),
+		damage:   planDamage(opts, opts.carNumber(), opts.driver(), start, opts.DamageEvents),
+		failure:  planEngineFailure(opts, opts.carNumber(), opts.EngineFailures),
+		laps:     plans.carLaps(opts, opts.carNumber()),
+		start:    start,
+	}.withinSession()
+}
+
+// competitorCar returns the setup of a competitor, with pace taken from its
+// last lap time less the effect of its tires, and its laps from plans
+func competitorCar(opts Options, c Competitor, plans *sessionPlans) carSetup {
+	p := opts.parameters()
+	driver := competitorDriver(opts, c)
+	baseLapTime := c.LastLapTime / opts.tireModel().lastLapFactor(c.TireCompound, driver, c.TireAge)
+	start := planStart(opts, c.CarNumber, c.Position, driver)
+	return carSetup{
+		number:   c.CarNumber,
+		driver:   driver,
+		pace:     p.ReferenceLapTime / baseLapTime,
+		fuel:     c.FuelLoadEstimate,
+		tireAge:  c.TireAge,
+		compound: c.TireCompound,
+		stops:    planPitStops(opts, c.CarNumber, tirePitLaps(c.TireAge, opts.Laps), nil, c.TireCompound),
+		damage:   planDamage(opts, c.CarNumber, driver, start, nil),
+		failure:  planEngineFailure(opts, c.CarNumber, nil),
+		laps:     plans.carLaps(opts, c.CarNumber),
+		start:    start,
+	}.withinSession()
+}
+
+// telemetryModel holds everything needed to generate one car's laps
+type telemetryModel struct {
+	params        *RaceParameters
+	pitLane       PitLane
+	tires         tireModel
+	cal           Calibration
+	car           carSetup
+	sampleRate    float64 // samples per second
+	samplesPerLap int
+}
+
+// newTelemetryModel prepares the telemetry model of car
+func newTelemetryModel(opts Options, car carSetup) *telemetryModel {
+	p := opts.parameters()
+	sampleRate := opts.sampleRate()
+	return &telemetryModel{
+		params:        p,
+		pitLane:       opts.pitLane(),
+		tires:         opts.tireModel(),
+		cal:           car.driver.adjust(*opts.calibration()),
+		car:           car,
+		sampleRate:    sampleRate,
+		samplesPerLap: int(p.ReferenceLapTime * sampleRate),
+	}
+}
+
+// pitPhase says where a sample is relative to the pit lane and the grid
+type pitPhase uint8
+
+const (
+	onTrack    pitPhase = iota
+	inPitLane           // driving the pit lane on the limiter
+	inPitBox            // stationary in the pit box
+	retiring            // rolling to a stop after the engine fails
+	onGrid              // stationary in the grid slot before getting away
+	recovering          // crawling on after contact in the first corner
+)
+
+// lapPlan is the part of a lap that depends on the laps before it: the
+// driven speed trace and the corners the driver gets wrong. Laps run
+// through the pit lane, below racing speed or ending in retirement also
+// carry the lap progress and pit phase of every sample, other laps advance
+// linearly. Laps after the car retires or its session ends have no samples
+type lapPlan struct {
+	lap        int
+	kind       string  // lap type, LapRacing and so on
+	stintLap   int     // laps into the current stint within the session, from 1
+	startTime  float64 // session time of the first sample
+	compound   string
+	tireAge    int     // laps on the tires at the start of the lap
+	tireDeg    float64 // lap time multiplier of the tires
+	tireTemp   float64 // °C the tires run above the calibrated temperatures
+	brakeBias  float64 // percent to the front
+	mistakes   []mistake
+	speeds     []float64
+	entrySpeed float64 // speed of the sample before the lap
+	progress   []float64
+	phase      []pitPhase
+	curvatures []float64             // signed track curvature under each sample, 1/m
+	steering   []float64             // steering wheel angle at each sample
+	brakeTemps [][wheelCount]float64 // disc temperatures after each sample
+	engine     []engineState         // power unit state after each sample
+	launchEnd  int                   // sample the clutch is fully out after a race start
+}
+
+// progressAt returns the lap progress of sample
+func (l *lapPlan) progressAt(sample, samplesPerLap int) float64 {
+	if l.progress != nil {
+		return l.progress[sample]
+	}
+	return float64(sample) / float64(samplesPerLap)
+}
+
+// phaseAt returns the pit phase of sample
+func (l *lapPlan) phaseAt(sample int) pitPhase {
+	if l.phase != nil {
+		return l.phase[sample]
+	}
+	return onTrack
+}
+
+// lapPlanner plans a car's laps in order, carrying speed noise, the speed
+// across the line, the steering wheel, the fuel load and tire age from one
+// lap to the next
+type lapPlanner struct {
+	model      *telemetryModel
+	s          *sampler
+	speedNoise *ouProcess
+	steering   *steeringModel
+	lastSpeed  float64
+	fuel       float64
+	compound   string
+	tireLaps   int     // laps on the current tires
+	stintLap   int     // laps into the current stint within the session
+	extraTime  float64 // seconds beyond racing laps: in the pit lane, on slow laps and in the garage
+	brakeTemps [wheelCount]float64
+	brakeBias  float64
+	frontLock  bool // a front wheel locked on the last lap
+	engine     engineState
+	retired    bool
+	index      int // next lap of the session plan
+	lap        int
+}
+
+// newPlanner creates a planner for the first lap drawing from s. The first
+// lap of the session plan starts at time zero
+func (m *telemetryModel) newPlanner(s *sampler) *lapPlanner {
+	var first int
+	if len(m.car.laps) > 0 {
+		first = m.car.laps[0].lap
+	}
+	steering := newSteeringModel(1 / m.sampleRate)
+	steering.start(s)
+	return &lapPlanner{
+		model:      m,
+		s:          s,
+		speedNoise: newOUProcess(m.cal.SpeedNoise, m.cal.SpeedTau, 1/m.sampleRate),
+		steering:   steering,
+		fuel:       m.car.fuel,
+		compound:   m.car.compound,
+		tireLaps:   m.car.tireAge,
+		brakeTemps: [wheelCount]float64{brakeStartTemp, brakeStartTemp, brakeStartTemp, brakeStartTemp},
+		brakeBias:  m.car.driver.baseBrakeBias(),
+		engine:     engineStartState,
+		extraTime:  -float64(first-1) * m.params.ReferenceLapTime,
+	}
+}
+
+// startRun sends the car out of the garage for a new run on fresh tires,
+// with the brakes and power unit cooled down. The run starts on the sample
+// nearest its start time, keeping every sample time on the session's grid
+func (pl *lapPlanner) startRun(sl sessionLap) {
+	pl.fuel, pl.compound = sl.fuel, sl.compound
+	pl.tireLaps, pl.stintLap = 0, 0
+	pl.brakeTemps = [wheelCount]float64{brakeStartTemp, brakeStartTemp, brakeStartTemp, brakeStartTemp}
+	pl.engine = engineStartState
+	pl.lastSpeed = 0
+	start := math.Round(sl.start*pl.model.sampleRate) / pl.model.sampleRate
+	pl.extraTime = start - float64(sl.lap-1)*pl.model.params.ReferenceLapTime
+}
+
+// next plans the following lap
+func (pl *lapPlanner) next() lapPlan {
+	m, s := pl.model, pl.s
+	p, driver := m.params, m.car.driver
+	if pl.retired || pl.index >= len(m.car.laps) {
+		pl.lap++
+		return lapPlan{lap: pl.lap}
+	}
+	sl := m.car.laps[pl.index]
+	pl.index++
+	pl.lap = sl.lap
+	if sl.newRun {
+		pl.startRun(sl)
+	}
+	pl.stintLap++
+	fromRest := sl.standing || sl.newRun
+	toRest := pl.index < len(m.car.laps) && m.car.laps[pl.index].standing
+
+	// Tire degradation, warm-up and temperature window of the compound
+	tireDeg, tireTemp := m.tires.lap(pl.compound, driver, pl.tireLaps, pl.stintLap)
+
+	// Fuel load effect (lighter car = faster)
+	fuelEffect := fuelEffect(p, pl.fuel)
+
+	// Driver's pace this lap, with the tire and fuel effects, and the
+	// corners they get wrong
+	pace := m.car.pace * (1 + s.normalRandom(0, driver.paceSigma())) * fuelEffect / tireDeg
+	pl.brakeBias = driver.nextBrakeBias(s, pl.brakeBias, pl.frontLock)
+	mistakes := driver.drawMistakes(s, lockUpScale(driver, tireDeg, tireTemp, pl.brakeBias))
+
+	// Laps into or out of the pit lane take a different course, and laps
+	// below racing speed or held up in traffic take longer. A lap the
+	// timing model has timed takes that long on track, at the pace it sets
+	stop, pitIn := pitStopAt(m.car.stops, pl.lap)
+	_, pitOut := pitStopAt(m.car.stops, pl.lap-1)
+	garage := sl.kind == LapIn
+	if garage {
+		stop, pitIn = PitStop{StationaryTime: garageStop}, true
+	}
+	pitOut = pitOut || sl.newRun
+	var progress []float64
+	var phase []pitPhase
+	samples := m.samplesPerLap
+	lapSamples := lapSamples(p, m.sampleRate, sl.kind)
+	if sl.lapTime > 0 {
+		lapSamples = int(math.Round(sl.lapTime * m.sampleRate))
+		pace = float64(m.samplesPerLap) / float64(lapSamples) / lapPace[sl.kind]
+		pace *= aeroLapTimeFactor(p, damageAt(m.car.damage, p.AeroDamagePercentage, pl.lap, 1)) // Slowed below by the damage
+	}
+	held := int(math.Round(m.car.trafficLoss(pl.lap) * m.sampleRate))
+	if pitIn || pitOut || lapSamples != m.samplesPerLap || held > 0 {
+		progress, phase = m.lapLayout(lapSamples, held, stop, pitIn, pitOut, garage)
+		samples = len(progress)
+	}
+
+	// A race starts from the grid slot, where the formation lap forms up
+	// and waits for lights out
+	startTime := float64(pl.lap-1)*p.ReferenceLapTime + pl.extraTime
+	if start := m.car.start; start != nil && sl.standing && sl.kind == LapRacing {
+		progress, phase = m.startLayout(start, lapSamples, held, stop, pitIn)
+		samples = len(progress)
+	}
+	heldFrom, heldPace := m.heldSection(progress, phase, held, pitIn)
+	if start := m.car.start; start != nil && toRest {
+		progress, phase = m.formUp(progress, phase, startTime, start.LightsOut)
+		samples = len(progress)
+	}
+	f := m.car.failure
+	retire := f != nil && f.Retired && f.RetireLap == pl.lap
+	if retire {
+		progress, phase = m.retireLayout(progress, phase, f.RetireProgress)
+		samples = len(progress)
+	}
+	plan := lapPlan{
+		lap:       pl.lap,
+		kind:      sl.kind,
+		stintLap:  pl.stintLap,
+		startTime: startTime,
+		compound:  pl.compound,
+		tireAge:   pl.tireLaps,
+		tireDeg:   tireDeg,
+		tireTemp:  tireTemp,
+		brakeBias: pl.brakeBias,
+		mistakes:  mistakes,
+		progress:  progress,
+		phase:     phase,
+	}
+	if start := m.car.start; start != nil && sl.standing && sl.kind == LapRacing {
+		for plan.launchEnd < samples && phase[plan.launchEnd] == onGrid {
+			plan.launchEnd++
+		}
+		plan.launchEnd += int(math.Round(clutchRelease * m.sampleRate))
+	}
+
+	// Target speeds from the reference profile with tire, fuel and driver
+	// variation, then limited to what the car can accelerate and brake
+	targets := make([]float64, samples)
+	for sample := range targets {
+		lapProgress := plan.progressAt(sample, m.samplesPerLap)
+		target := m.cal.speedAt(lapProgress)*pace*lapPace[sl.kind] + pl.speedNoise.next(s)
+		if lapProgress >= heldFrom {
+			target *= heldPace
+		}
+		if mk, ok := mistakeAt(mistakes, lapProgress); ok {
+			target *= mk.speedFactor(lapProgress)
+		}
+		target *= aeroSpeedFactor(p, damageAt(m.car.damage, p.AeroDamagePercentage, pl.lap, lapProgress), target)
+		switch plan.phaseAt(sample) {
+		case inPitLane:
+			targets[sample] = m.pitLane.SpeedLimit
+		case inPitBox, onGrid:
+			targets[sample] = 0
+		case recovering:
+			targets[sample] = clamp(target*recoveryPace, 20, p.MaxSpeed)
+		default:
+			targets[sample] = clamp(target, 20, p.MaxSpeed) // Car speed capability
+		}
+	}
+
+	// Standing starts and runs from the garage pull away from rest, and the
+	// formation lap stops in the grid slot
+	if fromRest {
+		targets[0] = 0
+	}
+	if toRest {
+		targets[len(targets)-1] = 0
+	}
+	plan.entrySpeed = pl.lastSpeed
+	if pl.index == 1 || fromRest {
+		plan.entrySpeed = targets[0]
+	}
+	loop := progress == nil && !fromRest && !toRest
+	plan.speeds = m.cal.solveSpeedTrace(targets, 1/m.sampleRate, p.MaxSpeed, pl.lastSpeed, loop)
+	if retire {
+		m.coastToStop(&plan)
+		pl.retired = true
+	}
+	pl.lastSpeed = plan.speeds[len(plan.speeds)-1]
+	plan.curvatures = make([]float64, len(plan.speeds))
+	for sample := range plan.curvatures {
+		switch plan.phaseAt(sample) {
+		case inPitLane, inPitBox: // The pit lane runs straight
+		default:
+			plan.curvatures[sample] = curvature(plan.progressAt(sample, m.samplesPerLap), p.TrackLength)
+		}
+	}
+	plan.steering = m.steeringAngles(s, pl.steering, &plan)
+	plan.brakeTemps = brakeTemps(pl.brakeTemps, plan.speeds, plan.curvatures, 1/m.sampleRate, pl.brakeBias, p.MaxSpeed, p.TrackTemp)
+	pl.brakeTemps = plan.brakeTemps[len(plan.brakeTemps)-1]
+	plan.engine = m.engineStates(pl.engine, &plan)
+	pl.engine = plan.engine[len(plan.engine)-1]
+	pl.frontLock = false
+	for _, mk := range mistakes {
+		pl.frontLock = pl.frontLock || mk.kind == mistakeLockUp && mk.wheel < wheelRL
+	}
+	pl.fuel -= p.FuelPerLap(pl.fuel) * lapPace[sl.kind]
+	pl.extraTime += float64(samples-m.samplesPerLap) / m.sampleRate
+	pl.tireLaps++
+	if pitIn {
+		pl.tireLaps, pl.stintLap, pl.compound = 0, 0, stop.Compound
+	}
+
+	return plan
+}
+
+// lapLayout returns the lap progress and pit phase of every sample of a lap
+// of lapSamples samples that ends with stop in the pit box (pitIn), or at
+// the garage without driving on (garage), or starts in the pit lane
+// (pitOut). Track sections keep the sample spacing of the lap, apart from
+// held extra samples stuck in traffic from heldStart, and the pit lane is
+// driven at the speed limit
+func (m *telemetryModel) lapLayout(lapSamples, held int, stop PitStop, pitIn, pitOut, garage bool) ([]float64, []pitPhase) {
+	lane := m.pitLane
+	var progress []float64
+	var phase []pitPhase
+
+	// track appends the samples of the lap between two positions, spreading
+	// extra samples evenly among them
+	track := func(from, to float64, extra int) {
+		n := float64(lapSamples)
+		first, last := int(math.Ceil(from*n)), int(math.Ceil(to*n))
+		total := last - first + extra
+		for i := 0; i < total; i++ {
+			progress = append(progress, (float64(first)+float64(i*(last-first))/float64(total))/n)
+			phase = append(phase, onTrack)
+		}
+	}
+
+	start, end := 0.0, 1.0
+	if pitOut {
+		progress, phase = m.driveLane(progress, phase, 0, lane.Exit)
+		start = lane.Exit
+	}
+	if pitIn {
+		end = lane.Entry
+	}
+	split := math.Max(start, m.heldStart(pitIn))
+	track(start, split, 0)
+	track(split, end, held)
+	if pitIn {
+		progress, phase = m.pitInLayout(progress, phase, stop, garage)
+	}
+	return progress, phase
+}
+
+// driveLane appends the samples driving the pit lane from one lap progress
+// to another
+func (m *telemetryModel) driveLane(progress []float64, phase []pitPhase, from, to float64) ([]float64, []pitPhase) {
+	n := int(math.Max(1, math.Round(m.pitLane.laneSeconds(m.params, from, to)*m.sampleRate)))
+	for i := 0; i < n; i++ {
+		progress = append(progress, from+(to-from)*float64(i)/float64(n))
+		phase = append(phase, inPitLane)
+	}
+	return progress, phase
+}
+
+// pitInLayout appends the samples from the pit entry to the box, the stop,
+// and unless the car is back in the garage the lane on to the line
+func (m *telemetryModel) pitInLayout(progress []float64, phase []pitPhase, stop PitStop, garage bool) ([]float64, []pitPhase) {
+	lane := m.pitLane
+	progress, phase = m.driveLane(progress, phase, lane.Entry, lane.Box)
+	for i := 0; i < int(math.Round(stop.StationaryTime*m.sampleRate)); i++ {
+		progress = append(progress, lane.Box)
+		phase = append(phase, inPitBox)
+	}
+	if !garage {
+		progress, phase = m.driveLane(progress, phase, lane.Box, 1)
+	}
+	return progress, phase
+}
+
+// retireLayout cuts the lap progress and pit phase of a lap, nil for a
+// normal lap, down to the samples before the car retires at retireProgress
+func (m *telemetryModel) retireLayout(progress []float64, phase []pitPhase, retireProgress float64) ([]float64, []pitPhase) {
+	if progress == nil {
+		n := int(math.Ceil(retireProgress * float64(m.samplesPerLap)))
+		for i := 0; i < n; i++ {
+			progress = append(progress, float64(i)/float64(m.samplesPerLap))
+			phase = append(phase, onTrack)
+		}
+	}
+	if len(progress) == 0 {
+		return []float64{0}, []pitPhase{onTrack}
+	}
+	n := 1
+	for n < len(progress) && progress[n] < retireProgress {
+		n++
+	}
+	return progress[:n], phase[:n]
+}
+
+// coastToStop extends a lap cut short by retirement with the car rolling to
+// a stop, braking gently and harder only to stop before the line
+func (m *telemetryModel) coastToStop(plan *lapPlan) {
+	const (
+		kmh          = 3.6 // km/h per m/s
+		pullOverDecl = 4   // m/s² of braking as the driver pulls over
+	)
+	dt, lapLength := 1/m.sampleRate, m.params.TrackLength*1000
+	speed := plan.speeds[len(plan.speeds)-1] / kmh
+	progress := plan.progress[len(plan.progress)-1]
+	for speed > 0 {
+		remaining := math.Max((1-progress)*lapLength-1, 0.1)
+		decel := coastDeceleration(speed*kmh, m.params.MaxSpeed) + pullOverDecl
+		decel = math.Max(decel, speed*speed/(2*remaining))
+		speed = math.Max(speed-decel*dt, 0)
+		progress += speed * dt / lapLength
+		plan.speeds = append(plan.speeds, speed*kmh)
+		plan.progress = append(plan.progress, progress)
+		plan.phase = append(plan.phase, retiring)
+	}
+}
+
+// generateLap creates realistic Monaco F1 telemetry for one planned lap,
+// drawing every per-sample channel from s
+func (m *telemetryModel) generateLap(s *sampler, plan lapPlan) *TelemetryData {
+	p, cal, driver := m.params, &m.cal, m.car.driver
+
+	// Track characteristics
+	trackLength := p.TrackLength // km
+
+	sampleRate := m.sampleRate
+	samplesPerLap := m.samplesPerLap
+	timeScale := math.Pow10(timeDecimals(sampleRate))
+	lap, mistakes := plan.lap, plan.mistakes
+
+	data := newTelemetryData(len(plan.speeds))
+	data.SampleRate = sampleRate
+	for sample := range plan.speeds {
+		// Current time and position
+		currentTime := plan.startTime + float64(sample)/sampleRate
+		lapProgress := plan.progressAt(sample, samplesPerLap)
+		currentDistance := float64(lap-1)*trackLength + lapProgress*trackLength
+		phase := plan.phaseAt(sample)
+		aeroDamage := damageAt(m.car.damage, p.AeroDamagePercentage, lap, lapProgress)
+
+		speed := plan.speeds[sample]
+
+		// Throttle and brake based on speed and Monaco characteristics. In
+		// the pit lane the limiter holds the speed, the driver holds the
+		// brake while stationary in the box or pulling over to retire, and
+		// holds the revs on the clutch in the grid slot
+		inputs := cal.Inputs.zone(zoneOf(lapProgress, speed))
+		throttle := s.uniformRandom(inputs.Throttle.Min, inputs.Throttle.Max)
+		brakePressure := s.uniformRandom(inputs.Brake.Min, inputs.Brake.Max)
+		if mk, ok := mistakeAt(mistakes, lapProgress); ok && mk.kind == mistakeLockUp && lapProgress <= mk.apex {
+			brakePressure *= 1.3 // Over-braking into a lock-up
+		}
+		var pitLimiter int
+		switch phase {
+		case inPitLane:
+			pitLimiter = 1
+			throttle = s.uniformRandom(15, 30)
+			brakePressure = s.uniformRandom(0, 2)
+		case inPitBox:
+			pitLimiter = 1
+			throttle = 0
+			brakePressure = s.uniformRandom(20, 40)
+		case retiring:
+			throttle = 0
+			brakePressure = s.uniformRandom(5, 15)
+		case onGrid:
+			throttle = s.uniformRandom(35, 50)
+			brakePressure = 0
+		}
+		launch := m.launchAt(&plan, sample)
+
+		// Tire temperatures (Monaco is demanding on tires due to barriers and track surface)
+		// Base temperature rises with tire degradation each lap
+		tt := cal.TireTemps
+		stintLap := float64(plan.stintLap)
+		baseTemp := p.TrackTemp + driver.tireTempOffset() + plan.tireTemp
+		tireTempFL := tt.FL.sample(s, baseTemp+tt.FL.Offset+stintLap*tt.FL.PerLap, throttle, brakePressure)
+		tireTempFR := tt.FR.sample(s, baseTemp+tt.FR.Offset+stintLap*tt.FR.PerLap, throttle, brakePressure)
+		tireTempRL := tt.RL.sample(s, baseTemp+tt.RL.Offset+stintLap*tt.RL.PerLap, throttle, brakePressure)
+		tireTempRR := tt.RR.sample(s, baseTemp+tt.RR.Offset+stintLap*tt.RR.PerLap, throttle, brakePressure)
+
+		// Clamp tire temperatures to realistic ranges
+		tireTempFL = clamp(tireTempFL, 80, 140)
+		tireTempFR = clamp(tireTempFR, 80, 140)
+		tireTempRL = clamp(tireTempRL, 80, 140)
+		tireTempRR = clamp(tireTempRR, 80, 140)
+
+		// Fuel flow (higher at high throttle, limited by regulations)
+		fuelFlow := cal.FuelFlow.Base + (throttle * cal.FuelFlow.Throttle) + s.normalRandom(0, cal.FuelFlow.Noise)
+		fuelFlow = clamp(fuelFlow, 0, 110) // F1 fuel flow limit 110 kg/h
+
+		// Engine RPM based on speed and gear
+		rpm := engineRPM(speed) + s.normalRandom(0, cal.RPMNoise)
+		if launch.rpm > 0 {
+			rpm += launch.rpm - engineRPM(speed) // Held off the limiter for the start
+		}
+		rpmInt := int(clamp(rpm, 5000, 15000))
+
+		// DRS (very limited in Monaco - only small section before Sainte Devote)
+		var drsActive int
+		if phase == onTrack && lapProgress > 0.95 && lapProgress < 0.08 && speed > 120 && brakePressure < 15 {
+			drsActive = 1
+		} else {
+			drsActive = 0
+		}
+
+		// Battery deployment (ERS) - strategic in Monaco due to limited overtaking
+		var batteryDeployment float64
+		if plan.kind == LapFormation || plan.kind == LapCoolDown {
+			batteryDeployment = 0 // Recharging on the slow laps
+		} else if phase != onTrack {
+			batteryDeployment = 0 // No deployment on the limiter or once retiring
+		} else if lapProgress > 0.58 && lapProgress < 0.68 { // Tunnel section
+			batteryDeployment = s.uniformRandom(120, 160) // Maximum deployment
+		} else if throttle > 75 {
+			batteryDeployment = s.uniformRandom(60, 120)
+		} else {
+			batteryDeployment = s.uniformRandom(0, 40)
+		}
+
+		// Gear estimation based on Monaco characteristics
+		var gear int
+		if speed < 50 {
+			gear = int(math.Max(1, math.Min(2, math.Floor(speed/30)+1)))
+		} else if speed < 80 {
+			gear = int(math.Max(2, math.Min(4, math.Floor(speed/25)+1)))
+		} else if speed < 120 {
+			gear = int(math.Max(3, math.Min(6, math.Floor(speed/25)+1)))
+		} else {
+			gear = int(math.Max(5, math.Min(8, math.Floor(speed/30)+2)))
+		}
+
+		// Steering angle follows track curvature (Monaco requires constant steering input)
+		trackCurvature, steeringAngle := plan.curvatures[sample], plan.steering[sample]
+
+		// Wheel speeds from the corner geometry and longitudinal slip, with a
+		// locked wheel dropping below the rest under braking and the rears
+		// spinning up on a poor launch
+		prevSpeed := plan.entrySpeed
+		if sample > 0 {
+			prevSpeed = plan.speeds[sample-1]
+		}
+		accel := (speed - prevSpeed) / 3.6 * sampleRate
+		rolling := rollingSpeeds(speed, trackCurvature)
+		slip := cal.wheelSlip(speed, accel, p.MaxSpeed, plan.brakeBias)
+		var wheelSpeeds [wheelCount]float64
+		for w := range wheelSpeeds {
+			wheelSpeeds[w] = rolling[w] * (1 + slip[w])
+			if phase == onTrack {
+				wheelSpeeds[w] = lockedWheelSpeed(mistakes, w, lapProgress, wheelSpeeds[w])
+			}
+			if w >= wheelRL {
+				wheelSpeeds[w] += launch.spin
+			}
+			if rolling[w] > 0 {
+				slip[w] = wheelSpeeds[w]/rolling[w] - 1
+			}
+		}
+		slipAngleFront, slipAngleRear := slipAngles(steeringAngle, trackCurvature, speed)
+
+		// Power unit temperatures, oil pressure and turbo speed, with any
+		// engine failure developing
+		engine := plan.engine[sample]
+		severity := m.car.failure.severity(lap, lapProgress)
+		oilTemp := engine.oilTemp + s.normalRandom(0, 0.2)
+		waterTemp := engine.waterTemp + s.normalRandom(0, 0.2)
+		oilPressure := math.Max(engine.oilPressure(float64(rpmInt), m.car.failure.mode(), severity)+s.normalRandom(0, 0.05), 0)
+		turboSpeed := int(math.Max(engine.turboSpeed+s.normalRandom(0, 500), 0))
+
+		// Store data with proper rounding
+		data.Time = append(data.Time, math.Round(currentTime*timeScale)/timeScale)
+		data.Lap = append(data.Lap, lap)
+		data.Distance = append(data.Distance, roundDistance(currentDistance, float64(lap)*trackLength))
+		data.Speed = append(data.Speed, math.Round(speed*10)/10)
+		data.Throttle = append(data.Throttle, math.Round(throttle*10)/10)
+		data.BrakePressure = append(data.BrakePressure, math.Round(brakePressure*10)/10)
+		data.TireTempFL = append(data.TireTempFL, math.Round(tireTempFL*10)/10)
+		data.TireTempFR = append(data.TireTempFR, math.Round(tireTempFR*10)/10)
+		data.TireTempRL = append(data.TireTempRL, math.Round(tireTempRL*10)/10)
+		data.TireTempRR = append(data.TireTempRR, math.Round(tireTempRR*10)/10)
+		data.FuelFlow = append(data.FuelFlow, math.Round(fuelFlow*10)/10)
+		data.EngineRPM = append(data.EngineRPM, rpmInt)
+		data.DRSActive = append(data.DRSActive, drsActive)
+		data.BatteryDeployment = append(data.BatteryDeployment, math.Round(batteryDeployment*10)/10)
+		data.Gear = append(data.Gear, gear)
+		data.SteeringAngle = append(data.SteeringAngle, math.Round(steeringAngle*10)/10)
+		data.PitLimiter = append(data.PitLimiter, pitLimiter)
+		data.AeroDamage = append(data.AeroDamage, aeroDamage)
+		discs := plan.brakeTemps[sample]
+		data.BrakeTempFL = append(data.BrakeTempFL, math.Round(discs[wheelFL]*10)/10)
+		data.BrakeTempFR = append(data.BrakeTempFR, math.Round(discs[wheelFR]*10)/10)
+		data.BrakeTempRL = append(data.BrakeTempRL, math.Round(discs[wheelRL]*10)/10)
+		data.BrakeTempRR = append(data.BrakeTempRR, math.Round(discs[wheelRR]*10)/10)
+		data.BrakeBias = append(data.BrakeBias, plan.brakeBias)
+		data.WheelSpeedFL = append(data.WheelSpeedFL, math.Round(wheelSpeeds[wheelFL]*10)/10)
+		data.WheelSpeedFR = append(data.WheelSpeedFR, math.Round(wheelSpeeds[wheelFR]*10)/10)
+		data.WheelSpeedRL = append(data.WheelSpeedRL, math.Round(wheelSpeeds[wheelRL]*10)/10)
+		data.WheelSpeedRR = append(data.WheelSpeedRR, math.Round(wheelSpeeds[wheelRR]*10)/10)
+		data.SlipFL = append(data.SlipFL, math.Round(slip[wheelFL]*1000)/10)
+		data.SlipFR = append(data.SlipFR, math.Round(slip[wheelFR]*1000)/10)
+		data.SlipRL = append(data.SlipRL, math.Round(slip[wheelRL]*1000)/10)
+		data.SlipRR = append(data.SlipRR, math.Round(slip[wheelRR]*1000)/10)
+		data.SlipAngleFront = append(data.SlipAngleFront, math.Round(slipAngleFront*100)/100)
+		data.SlipAngleRear = append(data.SlipAngleRear, math.Round(slipAngleRear*100)/100)
+		data.OilTemp = append(data.OilTemp, math.Round(oilTemp*10)/10)
+		data.WaterTemp = append(data.WaterTemp, math.Round(waterTemp*10)/10)
+		data.OilPressure = append(data.OilPressure, math.Round(oilPressure*100)/100)
+		data.TurboSpeed = append(data.TurboSpeed, turboSpeed)
+		data.Clutch = append(data.Clutch, math.Round(launch.clutch*10)/10)
+	}
+
+	return data
+}
+
+// roundDistance rounds distance to the metre without reaching lapEnd, so
+// the last samples of a lap never read as the start of the next
+func roundDistance(distance, lapEnd float64) float64 {
+	rounded := math.Round(distance*1000) / 1000
+	if rounded >= math.Round(lapEnd*1000)/1000 {
+		rounded = math.Floor(distance*1000) / 1000
+	}
+	return rounded
+}
+
+// timeDecimals returns the number of decimal places needed to write the
+// sample times of sampleRate exactly, at least one
+func timeDecimals(sampleRate float64) int {
+	if sampleRate <= 0 {
+		return 1
+	}
+	for decimals := 1; decimals < 6; decimals++ {
+		steps := math.Pow10(decimals) / sampleRate
+		if math.Abs(steps-math.Round(steps)) < 1e-9 {
+			return decimals
+		}
+	}
+	return 6
+}
@@ -0,0 +1,323 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+)
+
+// Timing feed message types
+const (
+	MessageLapCompleted   = "lap_completed"
+	MessagePositionChange = "position_change"
+	MessagePitIn          = "pit_in"
+	MessagePitOut         = "pit_out"
+	MessageGapUpdate      = "gap_update"
+	MessageTrackStatus    = "track_status"
+	MessageRetirement     = "retirement"
+	MessageElimination    = "elimination"
+	MessageBlueFlag       = "blue_flag"
+	MessageOvertake       = "overtake"
+)
+
+// TimingMessage is a single message of the live timing feed
+type TimingMessage struct {
+	SessionTime float64     `json:"session_time"`
+	Type        string      `json:"type"`
+	Data        interface{} `json:"data"`
+}
+
+// LapCompletedData is the payload of a lap_completed message
+type LapCompletedData struct {
+	CarNumber int     `json:"car_number"`
+	Lap       int     `json:"lap"`
+	LapTime   float64 `json:"lap_time"`
+	Position  int     `json:"position"`
+}
+
+// PositionChangeData is the payload of a position_change message
+type PositionChangeData struct {
+	CarNumber        int `json:"car_number"`
+	PreviousPosition int `json:"previous_position"`
+	Position         int `json:"position"`
+}
+
+// PitData is the payload of pit_in and pit_out messages
+type PitData struct {
+	CarNumber      int     `json:"car_number"`
+	Lap            int     `json:"lap"`
+	TireCompound   string  `json:"tire_compound"`
+	PitStops       int     `json:"pit_stops"`
+	StationaryTime float64 `json:"stationary_time,omitempty"` // seconds in the box, on pit_out
+}
+
+// GapUpdateData is the payload of a gap_update message
+type GapUpdateData struct {
+	CarNumber   int     `json:"car_number"`
+	Position    int     `json:"position"`
+	GapToLeader float64 `json:"gap_to_leader"`
+	Interval    float64 `json:"interval"`
+}
+
+// RetirementData is the payload of a retirement message
+type RetirementData struct {
+	CarNumber int    `json:"car_number"`
+	Lap       int    `json:"lap"`
+	Reason    string `json:"reason"`
+}
+
+// EliminationData is the payload of an elimination message, sent for each
+// car knocked out at the end of a qualifying segment
+type EliminationData struct {
+	CarNumber int `json:"car_number"`
+	Segment   int `json:"segment"`  // 1 for Q1, 2 for Q2
+	Position  int `json:"position"` // final qualifying position
+}
+
+// TrackStatusData is the payload of a track_status message
+type TrackStatusData struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// timingCar holds the race state of one car while building the feed
+type timingCar struct {
+	carNumber    int
+	pace         float64 // lap time in seconds before the tire effect
+	sessionTime  float64 // time the car last crossed the line
+	lapStart     float64 // time the car started the lap it last completed
+	lap          int
+	position     int
+	tireCompound string
+	tireAge      int
+	stintLaps    int // laps completed on the current tires within the session
+	pitStops     int
+	stops        []PitStop     // stops planned within the session
+	damage       []DamageEvent // aero damage picked up within the session
+	failure      *EngineFailure
+	start        *RaceStart
+	retired      bool
+	driver       Driver
+}
+
+// generateTimingFeed runs a lap-by-lap race model seeded from the competitor
+// data and returns the resulting timing feed ordered by session time. Cars
+// stop as planned in the ground truth, losing the time the telemetry shows in
+// the lane, lose pace to the aero damage they pick up and drop out of the
+// classification when their engine fails. Cars try to pass slower cars
+// ahead, are held up by them when they fail and lap backmarkers; the time
+// each loses to traffic and the overtakes attempted are added to gt, and
+// the lap times before it to plans for the telemetry to follow. A race
+// starts from the grid after the formation lap, each car losing the time its
+// start cost it on lap 1 and traffic settling from lap 2; practice and
+// qualifying have a feed of their own. The model stops between laps when ctx
+// is cancelled
+func generateTimingFeed(ctx context.Context, s *sampler, opts Options, competitors []Competitor, plans *sessionPlans, gt *GroundTruth) ([]TimingMessage, error) {
+	if !opts.racing() {
+		return generateSessionFeed(ctx, s, opts, competitors, plans, *gt)
+	}
+	p, totalLaps := opts.parameters(), opts.Laps
+	lapTimeBase := p.ReferenceLapTime
+	tires := opts.tireModel()
+	race := opts.session() == SessionRace
+	var raceStart float64
+	if race {
+		raceStart = lightsOut(opts)
+	}
+
+	// Every car starts from its place in the standings at its last lap's
+	// pace, less the effect of the tires it ran on. In a race the standings
+	// are the grid and every car gets away at lights out
+	var cars []*timingCar
+	for _, comp := range competitors {
+		driver := competitorDriver(opts, comp)
+		car := &timingCar{
+			carNumber:    comp.CarNumber,
+			pace:         comp.LastLapTime / tires.lastLapFactor(comp.TireCompound, driver, comp.TireAge),
+			sessionTime:  comp.GapToLeader,
+			position:     comp.Position,
+			tireCompound: comp.TireCompound,
+			tireAge:      comp.TireAge,
+			pitStops:     comp.PitStops,
+			driver:       driver,
+		}
+		if race {
+			car.sessionTime = raceStart
+			car.pitStops = 0
+		}
+		car.lapStart = car.sessionTime
+		for _, stop := range gt.PitStops {
+			if stop.CarNumber == comp.CarNumber {
+				car.stops = append(car.stops, stop)
+			}
+		}
+		for _, e := range gt.Damage {
+			if e.CarNumber == comp.CarNumber {
+				car.damage = append(car.damage, e)
+			}
+		}
+		for i, f := range gt.EngineFailures {
+			if f.CarNumber == comp.CarNumber && f.Retired {
+				car.failure = &gt.EngineFailures[i]
+			}
+		}
+		for i, start := range gt.Starts {
+			if start.CarNumber == comp.CarNumber {
+				car.start = &gt.Starts[i]
+			}
+		}
+		cars = append(cars, car)
+	}
+
+	var feed []TimingMessage
+	var traffic []TrafficDelay
+	var overtakes []Overtake
+	tm := trafficModel{newSampler(deriveSeed(opts.Seed, overtakeStream)), p, opts.overtaking()}
+	if race {
+		feed = append(feed,
+			TimingMessage{0, MessageTrackStatus, TrackStatusData{"formation", "Formation lap"}},
+			TimingMessage{round3(raceStart), MessageTrackStatus, TrackStatusData{"green", "Race start"}},
+		)
+	} else {
+		feed = append(feed, TimingMessage{0, MessageTrackStatus, TrackStatusData{"green", "Track clear"}})
+	}
+
+	for lap := 1; lap <= totalLaps; lap++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// Occasional local yellow flag somewhere on the lap
+		if s.float64() < 0.1 {
+			start := raceStart + float64(lap-1)*lapTimeBase + s.uniformRandom(5, 60)
+			end := start + s.uniformRandom(8, 20)
+			feed = append(feed,
+				TimingMessage{round3(start), MessageTrackStatus, TrackStatusData{"yellow", "Yellow flag in sector"}},
+				TimingMessage{round3(end), MessageTrackStatus, TrackStatusData{"green", "Track clear"}},
+			)
+		}
+
+		lapTimes := make(map[int]float64, len(cars)) // car number -> lap time
+		var running []*timingCar
+		for _, car := range cars {
+			if car.retired {
+				continue
+			}
+			tireFactor, _ := tires.lap(car.tireCompound, car.driver, car.tireAge, car.stintLaps+1)
+			damage := damageAt(car.damage, p.AeroDamagePercentage, lap, 1)
+			lapTime := car.pace*tireFactor*aeroLapTimeFactor(p, damage) + s.normalRandom(0, car.driver.lapTimeSigma(car.pace))
+			for range monacoCorners {
+				if s.float64() < car.driver.MistakeProbability {
+					lapTime += s.uniformRandom(0.5, 2.5) // Lock-up or missed apex
+				}
+			}
+			plans.setLapTime(car.carNumber, lap, lapTime)
+			if car.start != nil && lap == 1 {
+				lapTime += car.start.lapOneLoss()
+			}
+			if car.failure != nil && car.failure.RetireLap == lap {
+				car.retired = true
+				feed = append(feed, TimingMessage{round3(car.sessionTime + lapTime*car.failure.RetireProgress), MessageRetirement, RetirementData{
+					CarNumber: car.carNumber,
+					Lap:       lap,
+					Reason:    car.failure.Mode,
+				}})
+				continue
+			}
+			running = append(running, car)
+			lapTimes[car.carNumber] = lapTime
+		}
+		if !race || lap > 1 {
+			delays, attempts, messages := tm.resolve(running, lapTimes, lap)
+			traffic = append(traffic, delays...)
+			overtakes = append(overtakes, attempts...)
+			feed = append(feed, messages...)
+		}
+
+		lapMessages := make(map[int]int, len(cars)) // car number -> index of its lap_completed message
+		for _, car := range running {
+			lapTime := lapTimes[car.carNumber]
+			car.lapStart = car.sessionTime
+			car.sessionTime += lapTime
+			car.lap = lap
+			car.tireAge++
+			car.stintLaps++
+			lapMessages[car.carNumber] = len(feed)
+			feed = append(feed, TimingMessage{round3(car.sessionTime), MessageLapCompleted, LapCompletedData{
+				CarNumber: car.carNumber,
+				Lap:       lap,
+				LapTime:   round3(lapTime),
+			}})
+
+			if stop, ok := pitStopAt(car.stops, lap); ok {
+				pitIn := car.sessionTime
+				feed = append(feed, TimingMessage{round3(pitIn), MessagePitIn, PitData{car.carNumber, lap, car.tireCompound, car.pitStops, 0}})
+
+				car.sessionTime += stop.TimeLoss
+				car.pitStops++
+				car.tireAge = 0
+				car.stintLaps = 0
+				car.tireCompound = stop.Compound
+				feed = append(feed, TimingMessage{round3(car.sessionTime), MessagePitOut, PitData{car.carNumber, lap, car.tireCompound, car.pitStops, stop.StationaryTime}})
+			}
+		}
+
+		// Classify the lap by crossing time and publish positions and gaps
+		order := running
+		if len(order) == 0 {
+			continue
+		}
+		sort.Slice(order, func(i, j int) bool { return order[i].sessionTime < order[j].sessionTime })
+		leaderTime := order[0].sessionTime
+		for i, car := range order {
+			position := i + 1
+			lapData := feed[lapMessages[car.carNumber]].Data.(LapCompletedData)
+			lapData.Position = position
+			feed[lapMessages[car.carNumber]].Data = lapData
+
+			if car.position != position {
+				feed = append(feed, TimingMessage{round3(car.sessionTime), MessagePositionChange, PositionChangeData{
+					CarNumber:        car.carNumber,
+					PreviousPosition: car.position,
+					Position:         position,
+				}})
+				car.position = position
+			}
+
+			interval := 0.0
+			if i > 0 {
+				interval = car.sessionTime - order[i-1].sessionTime
+			}
+			feed = append(feed, TimingMessage{round3(car.sessionTime), MessageGapUpdate, GapUpdateData{
+				CarNumber:   car.carNumber,
+				Position:    position,
+				GapToLeader: round3(car.sessionTime - leaderTime),
+				Interval:    round3(interval),
+			}})
+		}
+	}
+
+	sort.SliceStable(feed, func(i, j int) bool { return feed[i].SessionTime < feed[j].SessionTime })
+	feed = append(feed, TimingMessage{feed[len(feed)-1].SessionTime, MessageTrackStatus, TrackStatusData{"chequered", "Chequered flag"}})
+	gt.Traffic, gt.Overtakes = traffic, overtakes
+	return feed, nil
+}
+
+// round3 rounds a value to millisecond precision
+func round3(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
+
+// WriteTimingFeed writes the timing feed to w as JSON lines, one message per line
+func WriteTimingFeed(w io.Writer, feed []TimingMessage) error {
+	encoder := json.NewEncoder(w)
+	for _, msg := range feed {
+		if err := encoder.Encode(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
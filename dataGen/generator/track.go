@@ -0,0 +1,120 @@
+package generator
+
+import "math"
+
+// trackSegment is one section of the lap with its reference speed curve
+type trackSegment struct {
+	Name  string
+	End   float64                           // lap progress at which the segment ends
+	speed func(lapProgress float64) float64 // reference speed in km/h
+}
+
+// monacoSegments describes the Monaco lap, in order, with realistic speeds
+var monacoSegments = []trackSegment{
+	{"Start/finish straight", 0.08, func(x float64) float64 { return 140 + 45*math.Sin(x*25) }},
+	{"Sainte Devote", 0.12, func(x float64) float64 { return 85 + 15*math.Sin(x*30) }},
+	{"Beau Rivage", 0.18, func(x float64) float64 { return 95 + 25*x*10 }},
+	{"Massenet and Casino Square", 0.25, func(x float64) float64 { return 70 + 20*math.Sin(x*15) }},
+	{"Mirabeau Haute approach", 0.32, func(x float64) float64 { return 110 + 30*x*8 }},
+	{"Mirabeau", 0.38, func(x float64) float64 { return 65 + 20*math.Sin(x*20) }},
+	{"Loews Hairpin approach", 0.45, func(x float64) float64 { return 80 + 25*x*6 }},
+	{"Loews Hairpin", 0.52, func(x float64) float64 { return 45 + 15*math.Sin(x*25) }}, // slowest corner
+	{"Portier", 0.58, func(x float64) float64 { return 75 + 30*x*5 }},
+	{"Tunnel", 0.68, func(x float64) float64 { return 110 + 70*x*3 }}, // fastest section
+	{"Nouvelle Chicane approach", 0.75, func(x float64) float64 { return 145 + 35*math.Sin(x*12) }},
+	{"Swimming Pool", 0.82, func(x float64) float64 { return 85 + 25*math.Sin(x*18) }},
+	{"La Rascasse", 0.88, func(x float64) float64 { return 70 + 20*x*4 }},
+	{"Anthony Noghes", 0.95, func(x float64) float64 { return 90 + 35*x*6 }},
+	{"Start/finish approach", 1.0, func(x float64) float64 { return 125 + 40*(1-x)*8 }},
+}
+
+// Major braking zones at Monaco: Sainte Devote, Mirabeau, Hairpin, Chicane, Anthony Noghes
+var brakingZones = []float64{0.12, 0.38, 0.52, 0.75, 0.88}
+
+// brakingZoneHalfWidth is how far either side of a braking zone centre the car brakes
+const brakingZoneHalfWidth = 0.025
+
+// getMonacoSpeedProfile returns realistic Monaco speed based on track position
+func getMonacoSpeedProfile(lapProgress float64) float64 {
+	return monacoSegments[segmentIndex(lapProgress)].speed(lapProgress)
+}
+
+// segmentIndex returns the index of the Monaco segment containing lapProgress
+func segmentIndex(lapProgress float64) int {
+	for i, segment := range monacoSegments {
+		if lapProgress < segment.End {
+			return i
+		}
+	}
+	return len(monacoSegments) - 1
+}
+
+// segmentStart returns the lap progress at which segment i begins
+func segmentStart(i int) float64 {
+	if i == 0 {
+		return 0
+	}
+	return monacoSegments[i-1].End
+}
+
+// inBrakingZone reports whether lapProgress falls in one of the major braking zones
+func inBrakingZone(lapProgress float64) bool {
+	for _, zone := range brakingZones {
+		if math.Abs(lapProgress-zone) < brakingZoneHalfWidth {
+			return true
+		}
+	}
+	return false
+}
+
+// Corner directions, matching the steering sign convention (positive left)
+const (
+	turnLeft  = 1.0
+	turnRight = -1.0
+)
+
+// corner is a single turn of the track
+type corner struct {
+	Name      string
+	Apex      float64 // lap progress of the apex
+	Direction float64 // turnLeft or turnRight
+	Radius    float64 // meters at the apex
+	Angle     float64 // degrees of heading change
+}
+
+// monacoCorners lists the Monaco turns in lap order, with apexes at the
+// speed profile's minima. The circuit runs clockwise, so most corners are
+// right-handers
+var monacoCorners = []corner{
+	{"Sainte Devote", 0.12, turnRight, 16, 90},
+	{"Massenet", 0.191, turnLeft, 15, 100},
+	{"Casino Square", 0.247, turnRight, 10, 70},
+	{"Mirabeau Haute", 0.321, turnRight, 12.5, 110},
+	{"Mirabeau Bas", 0.375, turnRight, 20, 80},
+	{"Loews Hairpin", 0.455, turnLeft, 10, 180},
+	{"Portier", 0.487, turnRight, 20, 90},
+	{"Tunnel", 0.63, turnRight, 150, 60},
+	{"Nouvelle Chicane entry", 0.745, turnLeft, 30, 45},
+	{"Nouvelle Chicane exit", 0.757, turnRight, 30, 45},
+	{"Tabac", 0.778, turnLeft, 40, 60},
+	{"Swimming Pool entry", 0.795, turnLeft, 35, 40},
+	{"Swimming Pool exit", 0.819, turnRight, 35, 40},
+	{"La Rascasse", 0.86, turnRight, 45, 120},
+	{"Anthony Noghes", 0.981, turnRight, 40, 90},
+}
+
+// curvature returns the signed track curvature in 1/m at lapProgress on a
+// track trackLength km long. Each corner is a raised-cosine bump spanning
+// twice its arc length, so curvature rises and falls smoothly
+func curvature(lapProgress, trackLength float64) float64 {
+	kappa := 0.0
+	for _, c := range monacoCorners {
+		arc := c.Radius * c.Angle * math.Pi / 180 / (trackLength * 1000) // lap progress
+		d := math.Abs(lapProgress - c.Apex)
+		d = math.Min(d, 1-d) // corners near the line wrap around
+		if d < arc {
+			kappa += c.Direction / c.Radius * 0.5 * (1 + math.Cos(math.Pi*d/arc))
+		}
+	}
+	return kappa
+}
@@ -0,0 +1,178 @@
+package generator
+
+import (
+	"math"
+	"sort"
+)
+
+// Traffic delay causes
+const (
+	TrafficHeldUp   = "held_up"   // stuck behind a slower car on the same lap
+	TrafficBlueFlag = "blue_flag" // moving aside for a car lapping it
+	TrafficLapping  = "lapping"   // passing a backmarker under blue flags
+)
+
+// TrafficDelay is time a car loses to another car on one lap, recorded in
+// the ground truth
+type TrafficDelay struct {
+	CarNumber int     `json:"car_number"`
+	Lap       int     `json:"lap"`
+	Cause     string  `json:"cause"`     // TrafficHeldUp, TrafficBlueFlag or TrafficLapping
+	OtherCar  int     `json:"other_car"` // the car ahead, or the car lapping or being lapped
+	TimeLost  float64 `json:"time_lost"` // seconds
+}
+
+// Traffic model. A faster car that catches a slower one on the same lap
+// tries to pass it, and if it fails follows it over the line unless the car
+// ahead is slow enough to cost it more than passingLoss, after a mistake
+// say; cars a lap down are shown blue flags and let the leaders by
+const (
+	followGap    = 0.4 // s behind the car ahead a held up car crosses the line
+	passingLoss  = 1.5 // s a car loses behind a much slower one before getting by
+	blueFlagLoss = 0.8 // s a backmarker loses moving aside
+	lappingLoss  = 0.3 // s the lapping car loses getting by
+)
+
+// BlueFlagData is the payload of a blue_flag message, shown to a car about
+// to be lapped
+type BlueFlagData struct {
+	CarNumber  int `json:"car_number"`
+	LappingCar int `json:"lapping_car"`
+	Lap        int `json:"lap"` // lap the lapping car is on
+}
+
+// trafficModel resolves the traffic on each lap of the timing model
+type trafficModel struct {
+	s          *sampler // overtake draws
+	params     *RaceParameters
+	overtaking OvertakeModel
+}
+
+// resolve settles the crossing times of the running cars on lap given the
+// free lap time of each, in order of the cars' starts of the lap. Each car
+// starts the lap at its session time. A car that would cross the line ahead
+// of the car that started just ahead of it tries to pass, and if it fails
+// ends the lap no sooner than followGap behind it. A car already on the road
+// ahead when this one starts the lap and still short of the line when it
+// gets there is lapped, losing the time on the lap it starts next. It
+// updates lapTimes with the traffic and returns the delays, the overtakes
+// attempted, and the blue flag and overtake messages
+func (t trafficModel) resolve(running []*timingCar, lapTimes map[int]float64, lap int) ([]TrafficDelay, []Overtake, []TimingMessage) {
+	order := append([]*timingCar(nil), running...)
+	sort.SliceStable(order, func(i, j int) bool { return order[i].sessionTime < order[j].sessionTime })
+
+	var delays []TrafficDelay
+	var overtakes []Overtake
+	var messages []TimingMessage
+	pending := make(map[int]float64, len(order)) // car number -> blue flag time still to lose
+	for i, car := range order {
+		start := car.sessionTime
+		end := start + lapTimes[car.carNumber] + pending[car.carNumber]
+
+		// Backmarkers on the road ahead that this car would reach before
+		// the line
+		for _, other := range order[i+1:] {
+			if other.lapStart >= start || other.sessionTime+followGap <= end {
+				continue
+			}
+			pending[other.carNumber] += blueFlagLoss
+			end += lappingLoss
+			delays = append(delays,
+				TrafficDelay{CarNumber: car.carNumber, Lap: lap, Cause: TrafficLapping, OtherCar: other.carNumber, TimeLost: lappingLoss},
+				TrafficDelay{CarNumber: other.carNumber, Lap: lap, Cause: TrafficBlueFlag, OtherCar: car.carNumber, TimeLost: blueFlagLoss},
+			)
+			messages = append(messages, TimingMessage{round3(other.sessionTime), MessageBlueFlag, BlueFlagData{other.carNumber, car.carNumber, lap}})
+		}
+
+		// The car that started the lap just ahead on the same lap
+		if i > 0 {
+			ahead := order[i-1]
+			aheadEnd := ahead.sessionTime + lapTimes[ahead.carNumber]
+			if end < aheadEnd {
+				o := t.attempt(car, ahead, end-start, lapTimes[ahead.carNumber], lap)
+				overtakes = append(overtakes, o)
+				messages = append(messages, TimingMessage{o.SessionTime, MessageOvertake, OvertakeData{o.CarNumber, o.DefendingCar, lap, o.Success}})
+				if o.Success {
+					aheadEnd = math.Inf(-1)
+				}
+			}
+			if held := aheadEnd + followGap; held > end {
+				lost := math.Min(held-end, passingLoss)
+				delays = append(delays, TrafficDelay{CarNumber: car.carNumber, Lap: lap, Cause: TrafficHeldUp, OtherCar: ahead.carNumber, TimeLost: round3(lost)})
+				end += lost
+			}
+		}
+		lapTimes[car.carNumber] = end - start
+	}
+	return delays, overtakes, messages
+}
+
+// attempt draws whether car, on course for a lap of lapTime, passes ahead,
+// on a lap of aheadLapTime, in the overtaking zone. The attacker is in the
+// slipstream if it started the lap within SlipstreamRange of the defender
+func (t trafficModel) attempt(car, ahead *timingCar, lapTime, aheadLapTime float64, lap int) Overtake {
+	p := t.params
+	aheadSpeed := lapSpeed(p, aheadLapTime)
+	gap := (car.sessionTime - ahead.sessionTime) * aheadSpeed / 3.6 // m
+	o := Overtake{
+		CarNumber:    car.carNumber,
+		DefendingCar: ahead.carNumber,
+		Lap:          lap,
+		SessionTime:  round3(ahead.sessionTime + aheadLapTime*overtakeZone + followGap),
+		SpeedDelta:   round3(lapSpeed(p, lapTime) - aheadSpeed),
+		Slipstream:   gap < p.SlipstreamRange,
+	}
+	o.Probability = round3(t.overtaking.probability(p, o.SpeedDelta, o.Slipstream))
+	o.Success = t.s.float64() < o.Probability
+	return o
+}
+
+// trafficLoss returns the seconds car loses to traffic on lap
+func (c carSetup) trafficLoss(lap int) float64 {
+	var loss float64
+	for _, d := range c.traffic {
+		if d.Lap == lap {
+			loss += d.TimeLost
+		}
+	}
+	return loss
+}
+
+// heldStart returns the lap progress from which a car loses its time in
+// traffic, stuck behind the car ahead or moving aside for a leader: the run
+// from the chicane to the line, where there is nowhere to pass. A lap into
+// a pit lane that leaves the track before the chicane loses it all lap
+func (m *telemetryModel) heldStart(pitIn bool) float64 {
+	if pitIn && m.pitLane.Entry <= overtakeZone {
+		return 0
+	}
+	return overtakeZone
+}
+
+// heldSection returns the lap progress from which a lap laid out with held
+// extra samples in traffic is driven below free-air speed, and the fraction
+// of it the car is held to there
+func (m *telemetryModel) heldSection(progress []float64, phase []pitPhase, held int, pitIn bool) (float64, float64) {
+	if held == 0 {
+		return math.Inf(1), 1
+	}
+	from := m.heldStart(pitIn)
+	var n int
+	for i := range progress {
+		if phase[i] == onTrack && progress[i] >= from {
+			n++
+		}
+	}
+	return from, float64(n-held) / float64(n)
+}
+
+// withTraffic returns the car with its delays among traffic
+func (c carSetup) withTraffic(traffic []TrafficDelay) carSetup {
+	c.traffic = nil
+	for _, d := range traffic {
+		if d.CarNumber == c.number {
+			c.traffic = append(c.traffic, d)
+		}
+	}
+	return c
+}
@@ -0,0 +1,113 @@
+package generator
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// TestTraffic checks that held up cars lose no more than getting by costs,
+// that every backmarker lapped is shown a blue flag and that a car is only
+// lapped when it was on the road ahead as the faster car started the lap
+func TestTraffic(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Laps = 30
+	result, err := GenerateSession(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cars start a lap crossing the line, or leaving the pit lane
+	starts := make(map[[2]int]float64) // car number and lap -> session time
+	flags := make(map[BlueFlagData]bool)
+	for _, msg := range result.TimingFeed {
+		switch data := msg.Data.(type) {
+		case LapCompletedData:
+			starts[[2]int{data.CarNumber, data.Lap + 1}] = msg.SessionTime
+		case PitData:
+			starts[[2]int{data.CarNumber, data.Lap + 1}] = msg.SessionTime
+		case BlueFlagData:
+			flags[data] = true
+		}
+	}
+
+	causes := make(map[string]int)
+	blue := make(map[[3]int]bool) // backmarker, lapping car and lap
+	for _, d := range result.GroundTruth.Traffic {
+		causes[d.Cause]++
+		switch d.Cause {
+		case TrafficHeldUp:
+			if d.TimeLost <= 0 || d.TimeLost > passingLoss {
+				t.Errorf("car %d held up %.3fs by car %d on lap %d", d.CarNumber, d.TimeLost, d.OtherCar, d.Lap)
+			}
+		case TrafficBlueFlag:
+			blue[[3]int{d.CarNumber, d.OtherCar, d.Lap}] = true
+		}
+	}
+	for _, d := range result.GroundTruth.Traffic {
+		if d.Cause != TrafficLapping {
+			continue
+		}
+		if !blue[[3]int{d.OtherCar, d.CarNumber, d.Lap}] || !flags[BlueFlagData{d.OtherCar, d.CarNumber, d.Lap}] {
+			t.Errorf("car %d laps car %d on lap %d without a blue flag", d.CarNumber, d.OtherCar, d.Lap)
+		}
+		if started, start := starts[[2]int{d.OtherCar, d.Lap - 1}], starts[[2]int{d.CarNumber, d.Lap}]; started >= start {
+			t.Errorf("car %d laps car %d on lap %d, which started its lap at %v after %v", d.CarNumber, d.OtherCar, d.Lap, started, start)
+		}
+	}
+	if causes[TrafficHeldUp] == 0 || causes[TrafficLapping] == 0 || causes[TrafficLapping] != causes[TrafficBlueFlag] {
+		t.Errorf("traffic delays %v, want cars held up and lapped", causes)
+	}
+}
+
+// TestTrafficTelemetry checks that a lap lost to traffic runs long in our
+// car's telemetry by the time lost, all of it lost behind the car ahead
+// after the chicane and none in free air before
+func TestTrafficTelemetry(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Laps = 3
+	opts.EngineFailures = []EngineFailure{}
+	car := ourCar(opts, nil)
+	held := car.withTraffic([]TrafficDelay{
+		{CarNumber: car.number, Lap: 2, Cause: TrafficHeldUp, OtherCar: 1, TimeLost: 1.2},
+		{CarNumber: car.number + 1, Lap: 2, Cause: TrafficHeldUp, OtherCar: 1, TimeLost: 1},
+	})
+
+	planLaps := func(car carSetup) []lapPlan {
+		model := newTelemetryModel(opts, car)
+		planner := model.newPlanner(newSampler(opts.Seed))
+		var plans []lapPlan
+		for lap := 1; lap <= opts.Laps; lap++ {
+			plans = append(plans, planner.next())
+		}
+		return plans
+	}
+	free, slowed := planLaps(car), planLaps(held)
+	rate := opts.sampleRate()
+	samplesPerLap := newTelemetryModel(opts, car).samplesPerLap
+	for i := range free {
+		want := 0.0
+		if i+1 == 2 {
+			want = 1.2
+		}
+		if lost := float64(len(slowed[i].speeds)-len(free[i].speeds)) / rate; math.Abs(lost-want) > 1/rate {
+			t.Errorf("lap %d runs %.2fs long in traffic, want %.2fs", i+1, lost, want)
+		}
+	}
+
+	// From Sainte Devote, clear of the join with the previous lap, up to the
+	// braking zone before the chicane the lap is driven in free air
+	lap := 1
+	for i, speed := range free[lap].speeds {
+		progress := free[lap].progressAt(i, samplesPerLap)
+		if progress < monacoCorners[0].Apex {
+			continue
+		}
+		if progress >= overtakeZone-0.1 {
+			break
+		}
+		if slowed[lap].speeds[i] != speed {
+			t.Fatalf("lap 2 at progress %.3f: %.1f km/h held up, %.1f km/h in free air", free[lap].progressAt(i, samplesPerLap), slowed[lap].speeds[i], speed)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package generator
+
+import "math"
+
+// Wheel geometry and slip constants
+const (
+	trackWidthFront = 1.6  // meters
+	trackWidthRear  = 1.55 // meters
+	driveSlip       = 0.08 // rear slip ratio at the traction limit
+	brakeSlip       = 0.1  // slip ratio at the braking limit with an even bias
+	rearSlipAngle   = 1.0  // degrees of rear slip angle per g of lateral acceleration
+)
+
+// rollingSpeeds returns the free-rolling speed of each wheel in km/h. In a
+// corner of curvature kappa (1/m, positive to the left) the outside wheels
+// travel further than the inside ones
+func rollingSpeeds(speed, kappa float64) [wheelCount]float64 {
+	front, rear := kappa*trackWidthFront/2, kappa*trackWidthRear/2
+	return [wheelCount]float64{
+		speed * (1 - front), speed * (1 + front),
+		speed * (1 - rear), speed * (1 + rear),
+	}
+}
+
+// wheelSlip returns the longitudinal slip ratio of each wheel at speed km/h
+// accelerating at accel m/s²: the driven rears slip under traction and every
+// wheel slips under braking in proportion to its share of the brake bias
+func (c *Calibration) wheelSlip(speed, accel, maxSpeed, bias float64) [wheelCount]float64 {
+	var slip [wheelCount]float64
+	if accel > 0 {
+		traction := math.Min(accel/math.Max(c.accelerationLimit(speed, maxSpeed), 1), 1)
+		slip[wheelRL] = driveSlip * traction
+		slip[wheelRR] = driveSlip * traction
+		return slip
+	}
+	decel := -accel - coastDeceleration(speed, maxSpeed)
+	if decel <= 0 {
+		return slip
+	}
+	braking := math.Min(decel/c.brakingLimit(speed, maxSpeed), 1)
+	front, rear := -brakeSlip*braking*bias/50, -brakeSlip*braking*(100-bias)/50
+	return [wheelCount]float64{front, front, rear, rear}
+}
+
+// slipAngles returns the front and rear slip angles in degrees, positive to
+// the left, from the steering wheel angle in a corner of curvature kappa at
+// speed km/h. The rears slip with lateral load and the fronts by whatever
+// the steering adds beyond the corner's geometric angle
+func slipAngles(steeringAngle, kappa, speed float64) (front, rear float64) {
+	v := speed / 3.6
+	lateralG := clamp(v*v*kappa/standardGravity, -maxLateralG, maxLateralG)
+	rear = rearSlipAngle * lateralG
+	front = rear + steeringAngle/steeringRatio - wheelbase*kappa*180/math.Pi
+	return front, rear
+}
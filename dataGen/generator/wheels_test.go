@@ -0,0 +1,63 @@
+package generator
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// TestWheelSlip checks the direction of wheel speed differences and slip in
+// corners, under traction and under braking
+func TestWheelSlip(t *testing.T) {
+	cal := DefaultOptions().calibration()
+	const maxSpeed = 200
+
+	left := rollingSpeeds(100, 1.0/30)
+	if left[wheelFR] <= left[wheelFL] || left[wheelRR] <= left[wheelRL] {
+		t.Errorf("left-hander wheel speeds %v, want the right wheels outside and faster", left)
+	}
+	traction := cal.wheelSlip(80, 8, maxSpeed, 57)
+	if traction[wheelRL] <= 0 || traction[wheelFL] != 0 {
+		t.Errorf("slip under traction %v, want only the rears slipping forward", traction)
+	}
+	braking := cal.wheelSlip(150, -30, maxSpeed, 57)
+	for w, slip := range braking {
+		if slip >= 0 {
+			t.Errorf("slip under braking %v, want every wheel slipping back", braking)
+			break
+		}
+		if w < wheelRL && slip >= braking[wheelRL] {
+			t.Errorf("slip under braking %v, want the fronts slipping most with forward bias", braking)
+			break
+		}
+	}
+}
+
+// TestWheelSpeedsMatchSpeed checks the wheel speed channels cross-check
+// against the speed channel: on average the wheels turn at the car's speed
+// and the slip channels agree with the wheel speeds
+func TestWheelSpeedsMatchSpeed(t *testing.T) {
+	result, err := Generate(context.Background(), goldenOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := result.Telemetry
+	var speed, wheels float64
+	for i := 0; i < d.Len(); i++ {
+		speed += d.Speed[i]
+		wheels += (d.WheelSpeedFL[i] + d.WheelSpeedFR[i] + d.WheelSpeedRL[i] + d.WheelSpeedRR[i]) / 4
+		if d.Speed[i] < 30 {
+			continue
+		}
+		// Outside and inside wheels average out to the car's speed on each
+		// axle, so the front axle's slip tells its speed
+		front := (d.WheelSpeedFL[i] + d.WheelSpeedFR[i]) / 2
+		slip := (d.SlipFL[i] + d.SlipFR[i]) / 200
+		if math.Abs(front-d.Speed[i]*(1+slip)) > 0.5 {
+			t.Fatalf("sample %d: front wheels at %.1f km/h with %.1f%% slip, car at %.1f km/h", i, front, slip*100, d.Speed[i])
+		}
+	}
+	if math.Abs(wheels/speed-1) > 0.02 {
+		t.Errorf("wheels turn at %.3f of the car's speed on average", wheels/speed)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"dataGen/generator"
+)
+
+// Grid telemetry output modes
+const (
+	gridFiles  = "files"  // one telemetry CSV per car
+	gridSingle = "single" // one telemetry CSV with a car_number column
+)
+
+// gridFile is an open telemetry CSV of the grid
+type gridFile struct {
+	file   *os.File
+	writer *generator.TelemetryCSVWriter
+}
+
+// createGridFile creates filename and starts a telemetry CSV in it
+func createGridFile(filename string, withCar bool) (*gridFile, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	newWriter := generator.NewTelemetryCSVWriter
+	if withCar {
+		newWriter = generator.NewGridTelemetryCSVWriter
+	}
+	writer, err := newWriter(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &gridFile{file: file, writer: writer}, nil
+}
+
+// close flushes and closes the file
+func (g *gridFile) close() error {
+	if err := g.writer.Flush(); err != nil {
+		g.file.Close()
+		return err
+	}
+	return g.file.Close()
+}
+
+// writeGridTelemetry generates telemetry for every car in session and writes
+// it to outDir in the given mode. It returns the names of the files written
+// and the number of samples.
+func writeGridTelemetry(ctx context.Context, opts generator.Options, session *generator.Result, outDir, mode string) ([]string, int, error) {
+	if mode != gridFiles && mode != gridSingle {
+		return nil, 0, fmt.Errorf("unknown grid telemetry mode %q", mode)
+	}
+
+	files := map[int]*gridFile{}
+	var names []string
+	open := func(carNumber int) (*gridFile, error) {
+		key := carNumber
+		name := fmt.Sprintf("telemetry_car_%02d.csv", carNumber)
+		if mode == gridSingle {
+			key, name = 0, "telemetry_grid.csv"
+		}
+		if f, ok := files[key]; ok {
+			return f, nil
+		}
+		f, err := createGridFile(filepath.Join(outDir, name), mode == gridSingle)
+		if err != nil {
+			return nil, err
+		}
+		files[key] = f
+		names = append(names, name)
+		return f, nil
+	}
+
+	samples := 0
+	err := generator.StreamGridTelemetry(ctx, opts, session, func(carNumber int, lap *generator.TelemetryData) error {
+		f, err := open(carNumber)
+		if err != nil {
+			return err
+		}
+		samples += lap.Len()
+		return f.writer.WriteCar(carNumber, lap)
+	})
+	for _, f := range files {
+		if closeErr := f.close(); err == nil {
+			err = closeErr
+		}
+	}
+	return names, samples, err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// task is one named piece of work run alongside others
+type task struct {
+	name string
+	run  func() error
+}
+
+// runTasks runs every task concurrently and returns the error of the first
+// failing task in list order, prefixed with its name
+func runTasks(tasks []task) error {
+	errs := make([]error, len(tasks))
+	var wg sync.WaitGroup
+	for i, t := range tasks {
+		wg.Add(1)
+		go func(i int, t task) {
+			defer wg.Done()
+			errs[i] = t.run()
+		}(i, t)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("%s: %w", tasks[i].name, err)
+		}
+	}
+	return nil
+}
+
+// progress prints a single status line of the percentage of laps generated
+// per stream, redrawn whenever a percentage changes
+type progress struct {
+	mu      sync.Mutex
+	w       io.Writer
+	percent map[string]int
+	printed bool
+}
+
+// newProgress creates a progress line written to w
+func newProgress(w io.Writer) *progress {
+	return &progress{w: w, percent: map[string]int{}}
+}
+
+// track returns a generator progress callback for the stream called name
+func (p *progress) track(name string) func(done, total int) {
+	return func(done, total int) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		percent := done * 100 / total
+		if last, ok := p.percent[name]; ok && last == percent {
+			return
+		}
+		p.percent[name] = percent
+
+		names := make([]string, 0, len(p.percent))
+		for n := range p.percent {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		parts := make([]string, len(names))
+		for i, n := range names {
+			parts[i] = fmt.Sprintf("%s %3d%%", n, p.percent[n])
+		}
+		fmt.Fprintf(p.w, "\rGenerating %s", strings.Join(parts, ", "))
+		p.printed = true
+	}
+}
+
+// done ends the status line
+func (p *progress) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.printed {
+		fmt.Fprintln(p.w)
+	}
+}
@@ -29,7 +29,8 @@ namespace CSVReader
                     {
                         Value = r.Value,
                         Unit = r.Unit,
-                        Description = r.Description
+                        Description = r.Description,
+                        Type = r.Type
                     }
                 ));
         }
@@ -40,6 +41,14 @@ namespace CSVReader
             public string Value { get; set; }
             public string Unit { get; set; }
             public string Description { get; set; }
+            public string Type { get; set; }
+
+            public object TypedValue => Type switch
+            {
+                "int" => int.Parse(Value, CultureInfo.InvariantCulture),
+                "float" => double.Parse(Value, CultureInfo.InvariantCulture),
+                _ => Value
+            };
         }
 
 
@@ -67,6 +76,12 @@ public class CompetitorData
     public double fuel_load_estimate { get; set; }
     public int tire_age { get; set; }
     public double distance_to_our_car { get; set; }
+    [Optional]
+    public double interval { get; set; }
+    [Optional]
+    public int laps_down { get; set; }
+    [Optional]
+    public bool our_car { get; set; }
 }
 public class RaceParams
 {
@@ -81,6 +96,10 @@ public class RaceParams
 
     [Name("description")]
     public string Description { get; set; }
+
+    [Name("type")]
+    [Optional]
+    public string Type { get; set; }
 }
 public class TelemetryData
 {